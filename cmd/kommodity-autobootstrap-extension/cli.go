@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"github.com/kommodity/talos-auto-bootstrap/internal/config"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/election"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/status"
+)
+
+// Subcommands. Running the binary without a subcommand is the same as "run".
+const (
+	commandRun    = "run"
+	commandScan   = "scan"
+	commandElect  = "elect"
+	commandStatus = "status"
+	commandHelp   = "help"
+)
+
+// Output formats for diagnostic subcommands.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+const usage = `Usage: kommodity-autobootstrap-extension [command] [flags]
+
+Commands:
+  run      Run the auto-bootstrap service (default)
+  scan     Scan the network for Talos nodes and print the results
+  elect    Run discovery and leader election without bootstrapping (--dry-run)
+  status   Print the local bootstrap state (or the election audit log with --audit)
+  help     Show this help
+
+Run "kommodity-autobootstrap-extension <command> -h" for command flags.
+`
+
+// runCommand runs a diagnostic subcommand. Diagnostic output goes to stdout,
+// logs only go to stderr at warn level so they don't mix with the output.
+func runCommand(ctx context.Context, command string, args []string) error {
+	zap.ReplaceGlobals(newCLILogger())
+
+	switch command {
+	case commandScan:
+		return runScan(ctx, args, os.Stdout)
+	case commandElect:
+		return runElect(ctx, args, os.Stdout)
+	case commandStatus:
+		return runStatus(args, os.Stdout)
+	case commandHelp, "-h", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// parseFlags parses args into fs and rejects positional arguments, which no
+// subcommand takes.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	return nil
+}
+
+// newCLILogger creates a logger for diagnostic subcommands writing to stderr.
+func newCLILogger() *zap.Logger {
+	cfg := zap.NewProductionConfig()
+	cfg.OutputPaths = []string{"stderr"}
+	cfg.Level = zap.NewAtomicLevelAt(zap.WarnLevel)
+
+	logger, err := cfg.Build()
+	if err != nil {
+		return zap.NewNop()
+	}
+
+	return logger
+}
+
+// scanFlags are the flags shared by the scan and elect subcommands.
+type scanFlags struct {
+	cfg         *config.Config
+	cidr        string
+	connected   bool
+	maxHosts    int
+	oversized   string
+	endpoint    string
+	timeout     time.Duration
+	concurrency int
+	preProbe    bool
+	rate        int
+	verbose     bool
+	output      string
+}
+
+// register adds the scan flags to fs, using cfg for defaults.
+func (f *scanFlags) register(fs *flag.FlagSet, cfg *config.Config) {
+	f.cfg = cfg
+
+	fs.StringVar(&f.cidr, "cidr", "", "comma-separated ranges to scan, e.g. 10.1.0.0/20!10.1.5.0/24 "+
+		"(defaults to the configured ranges or the local network)")
+	fs.BoolVar(&f.connected, "connected-subnets", cfg.ScanConnectedSubnets, "also scan every connected subnet")
+	fs.IntVar(&f.maxHosts, "max-hosts", cfg.ScanMaxHosts, "maximum number of hosts scanned per range")
+	fs.StringVar(&f.oversized, "oversized", cfg.ScanOversizedRanges, "oversized ranges: refuse or sample")
+	fs.StringVar(&f.endpoint, "endpoint", "", "control plane endpoint for the endpoint-route interface selection")
+	fs.DurationVar(&f.timeout, "timeout", cfg.ScanTimeout, "timeout for probing each node")
+	fs.IntVar(&f.concurrency, "concurrency", cfg.ScanConcurrency, "maximum concurrent node probes")
+	fs.IntVar(&f.rate, "rate", cfg.ScanRate, "maximum connection attempts per second (0 is unlimited)")
+	fs.BoolVar(&f.preProbe, "pre-probe", cfg.ScanPreProbe, "check the Talos API port with a TCP connect before probing")
+	fs.BoolVar(&f.verbose, "verbose", false, "print why each failed probe failed to stderr")
+	fs.StringVar(&f.output, "output", outputTable, "output format: table or json")
+}
+
+// scan resolves the network to scan and probes it for Talos nodes.
+func (f *scanFlags) scan(ctx context.Context) (*discovery.NetworkInfo, []discovery.DiscoveredNode, error) {
+	if f.output != outputTable && f.output != outputJSON {
+		return nil, nil, fmt.Errorf("unsupported output format %q", f.output)
+	}
+
+	if !slices.Contains(discovery.OversizedModes, f.oversized) {
+		return nil, nil, fmt.Errorf("unsupported oversized range handling %q", f.oversized)
+	}
+
+	policy, err := interfacePolicy(f.cfg, f.endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	netInfo, err := discovery.GetNetworkInfoWithPolicy(policy)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get network info: %w", err)
+	}
+
+	configured, err := scanRanges(f.cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if f.cidr != "" {
+		configured = nil
+		for _, cidr := range strings.Split(f.cidr, ",") {
+			r, err := discovery.ParseScanRange(cidr)
+			if err != nil {
+				return nil, nil, err
+			}
+			configured = append(configured, r)
+		}
+	}
+
+	opts := scanOptions(f.cfg)
+	opts.Timeout = f.timeout
+	opts.Concurrency = f.concurrency
+	opts.PreProbe = f.preProbe
+	if f.cfg.ScanNeighborSeeding {
+		opts.Seed = discovery.NeighborAddrs()
+	}
+
+	opts.Limiter = nil
+	if f.rate > 0 {
+		opts.Limiter = rate.NewLimiter(rate.Limit(f.rate), 1)
+	}
+
+	ranges, refused := discovery.LimitScanRanges(discovery.ScanRanges(netInfo, configured, f.connected),
+		f.maxHosts, f.oversized)
+	for _, r := range refused {
+		zap.L().Warn("not scanning oversized range",
+			zap.Stringer("range", r), zap.Int("hosts", r.HostCount()), zap.Int("max_hosts", f.maxHosts))
+	}
+
+	opts.Stats = discovery.NewScanStats(nil)
+	if f.verbose {
+		opts.Stats = discovery.NewScanStats(func(ip netip.Addr, err *discovery.ProbeError) {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", ip, err)
+		})
+	}
+
+	nodes, err := discovery.ScanRangesForTalosNodes(ctx, ranges, append(netInfo.LocalAddrs, netInfo.LocalIP), opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("scan failed: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "probe outcomes: %s\n", opts.Stats)
+
+	return netInfo, nodes, nil
+}
+
+// probeVersion probes ip like a peer and returns its Talos version, or an
+// empty string if the probe fails.
+func (f *scanFlags) probeVersion(ctx context.Context, ip netip.Addr) string {
+	opts := discovery.ScanOptions{Timeout: f.timeout, Concurrency: 1}
+	nodes, err := discovery.ScanRangesForTalosNodes(ctx,
+		[]discovery.ScanRange{{CIDR: netip.PrefixFrom(ip, ip.BitLen())}}, nil, opts)
+	if err != nil || len(nodes) == 0 {
+		return ""
+	}
+
+	return nodes[0].Version
+}
+
+// runScan implements the scan subcommand.
+func runScan(ctx context.Context, args []string, out io.Writer) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	var flags scanFlags
+	fs := flag.NewFlagSet(commandScan, flag.ContinueOnError)
+	flags.register(fs, cfg)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	_, nodes, err := flags.scan(ctx)
+	if err != nil {
+		return err
+	}
+
+	if flags.output == outputJSON {
+		if nodes == nil {
+			nodes = []discovery.DiscoveredNode{}
+		}
+		return writeJSON(out, nodes)
+	}
+
+	return writeNodeTable(out, nodes)
+}
+
+// electOutput is the JSON output of the elect subcommand.
+type electOutput struct {
+	Strategy       string                     `json:"strategy"`
+	QuorumRule     string                     `json:"quorumRule"`
+	QuorumRequired int                        `json:"quorumRequired"`
+	QuorumReached  bool                       `json:"quorumReached"`
+	QuorumMissing  []string                   `json:"quorumMissing,omitempty"`
+	Leader         *discovery.DiscoveredNode  `json:"leader"`
+	IsLeader       bool                       `json:"isLeader"`
+	Candidates     []discovery.DiscoveredNode `json:"candidates"`
+	Rejected       []string                   `json:"rejected"`
+	Notes          []string                   `json:"notes,omitempty"`
+}
+
+// runElect implements the elect subcommand. It never bootstraps.
+func runElect(ctx context.Context, args []string, out io.Writer) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	var (
+		flags  scanFlags
+		dryRun bool
+	)
+	fs := flag.NewFlagSet(commandElect, flag.ContinueOnError)
+	flags.register(fs, cfg)
+	fs.BoolVar(&dryRun, "dry-run", true, "print the election result without bootstrapping (always on)")
+	fs.IntVar(&cfg.QuorumNodes, "quorum-nodes", cfg.QuorumNodes, "number of control plane nodes required for quorum")
+	fs.StringVar(&cfg.ElectionStrategy, "strategy", cfg.ElectionStrategy, "election strategy: boot-time, lowest-ip, hostname or priority")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if !dryRun {
+		return fmt.Errorf("elect only supports --dry-run; bootstrapping is done by the run command")
+	}
+
+	// The flags override the config, so it is validated afterwards
+	if err := validateConfig(cfg); err != nil {
+		return err
+	}
+
+	strategy, err := election.NewStrategy(cfg.ElectionStrategy, cfg.ElectionPriorities)
+	if err != nil {
+		return err
+	}
+
+	netInfo, peers, err := flags.scan(ctx)
+	if err != nil {
+		return err
+	}
+
+	// No apid client: the local hostname and boot time come from the filesystem,
+	// and the Talos version from probing the local node like a peer
+	localNode, err := discovery.GetLocalNodeInfo(ctx, nil, netInfo.LocalIP)
+	if err != nil {
+		return fmt.Errorf("failed to get local node info: %w", err)
+	}
+	localNode.Version = flags.probeVersion(ctx, netInfo.LocalIP)
+
+	var notes []string
+	rules := eligibility(cfg)
+	if rules.MatchVersion && localNode.Version == "" {
+		rules.MatchVersion = false
+		notes = append(notes, "version matching skipped: the local Talos version could not be probed")
+	}
+
+	// The local node is not checked: without apid its stage and etcd state are unknown
+	peers, rejected := rules.FilterEligible(*localNode, peers)
+
+	result := election.ElectLeaderWithStrategy(strategy, *localNode, peers)
+	rule := quorumRule(cfg)
+	quorum := rule.Check(result.Candidates)
+	output := electOutput{
+		Strategy:       result.Strategy,
+		QuorumRule:     rule.String(),
+		QuorumRequired: quorum.Required,
+		QuorumReached:  quorum.Reached,
+		QuorumMissing:  quorum.Missing,
+		Leader:         result.Leader,
+		IsLeader:       result.IsLeader,
+		Candidates:     result.Candidates,
+		Rejected:       make([]string, 0, len(rejected)),
+		Notes:          notes,
+	}
+	for _, r := range rejected {
+		output.Rejected = append(output.Rejected, r.String())
+	}
+
+	if flags.output == outputJSON {
+		return writeJSON(out, output)
+	}
+
+	fmt.Fprintf(out, "Strategy:  %s\n", output.Strategy)
+	fmt.Fprintf(out, "Quorum:    %s (reached: %t)\n", output.QuorumRule, output.QuorumReached)
+	if len(output.QuorumMissing) > 0 {
+		fmt.Fprintf(out, "Missing:   %s\n", strings.Join(output.QuorumMissing, ", "))
+	}
+	fmt.Fprintf(out, "Leader:    %s (%s)\n", output.Leader.IP, output.Leader.Hostname)
+	fmt.Fprintf(out, "Is leader: %t\n", output.IsLeader)
+	for _, r := range output.Rejected {
+		fmt.Fprintf(out, "Rejected:  %s\n", r)
+	}
+	for _, note := range output.Notes {
+		fmt.Fprintf(out, "Note:      %s\n", note)
+	}
+	fmt.Fprintln(out)
+
+	return writeNodeTable(out, output.Candidates)
+}
+
+// runStatus implements the status subcommand.
+func runStatus(args []string, out io.Writer) error {
+	var (
+		dir    string
+		output string
+		audit  bool
+	)
+	fs := flag.NewFlagSet(commandStatus, flag.ContinueOnError)
+	fs.StringVar(&dir, "dir", statusDirDefault(), "status directory")
+	fs.StringVar(&output, "output", outputTable, "output format: table or json")
+	fs.BoolVar(&audit, "audit", false, "show the election audit log instead of the current state")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if audit {
+		return runAudit(dir, output, out)
+	}
+
+	state, err := status.Read(dir)
+	if err != nil {
+		return err
+	}
+
+	switch output {
+	case outputJSON:
+		return writeJSON(out, state)
+	case outputTable:
+		return writeStateTable(out, state)
+	default:
+		return fmt.Errorf("unsupported output format %q", output)
+	}
+}
+
+// runAudit prints the election audit log.
+func runAudit(dir, output string, out io.Writer) error {
+	records, err := status.ReadAudit(dir)
+	if err != nil {
+		return err
+	}
+
+	switch output {
+	case outputJSON:
+		if records == nil {
+			records = []status.AuditRecord{}
+		}
+		return writeJSON(out, records)
+	case outputTable:
+		return writeAuditTable(out, records)
+	default:
+		return fmt.Errorf("unsupported output format %q", output)
+	}
+}
+
+// statusDirDefault returns the configured status directory, falling back to
+// the default if the config cannot be loaded.
+func statusDirDefault() string {
+	cfg, err := config.Load()
+	if err != nil {
+		return status.DefaultDir
+	}
+
+	return cfg.StatusDir
+}
+
+// writeJSON writes v as indented JSON.
+func writeJSON(out io.Writer, v any) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeNodeTable writes discovered nodes as a table.
+func writeNodeTable(out io.Writer, nodes []discovery.DiscoveredNode) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tHOSTNAME\tCONTROL PLANE\tCREATION TIME\tPRIORITY\tVERSION\tSTAGE\tETCD\tCLUSTER\tSOURCE")
+	for _, node := range nodes {
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", node.IP, node.Hostname, node.IsControlPlane,
+			node.CreationTime.Format(time.RFC3339), node.Priority, node.Version, node.Stage, node.EtcdState,
+			node.ClusterName, node.Source)
+	}
+	return w.Flush()
+}
+
+// writeAuditTable writes election audit records as a table.
+func writeAuditTable(out io.Writer, records []status.AuditRecord) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tLAST TIME\tCOUNT\tSTRATEGY\tQUORUM\tCANDIDATES\tLEADER\tIS LEADER")
+	for _, record := range records {
+		candidates := make([]string, 0, len(record.Candidates))
+		for _, candidate := range record.Candidates {
+			candidates = append(candidates, candidate.IP.String())
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%t\n",
+			record.Time.Format(time.RFC3339), record.LastTime.Format(time.RFC3339), record.Count,
+			record.Strategy, record.QuorumRule, strings.Join(candidates, ","), record.Leader, record.IsLeader)
+	}
+	return w.Flush()
+}
+
+// writeStateTable writes the local bootstrap state as a key/value table.
+func writeStateTable(out io.Writer, state *status.State) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Phase:\t%s\n", state.Phase)
+	fmt.Fprintf(w, "Dry run:\t%t\n", state.DryRun)
+	fmt.Fprintf(w, "Local IP:\t%s\n", state.LocalIP)
+	fmt.Fprintf(w, "Peers found:\t%d\n", state.PeersFound)
+	for _, peer := range state.Peers {
+		fmt.Fprintf(w, "  %s:\t%s, %s, %s, etcd %s, cluster %s, via %s\n", peer.IP, peer.Hostname, peer.Version,
+			peer.Stage, peer.EtcdState, peer.ClusterName, peer.Source)
+	}
+	fmt.Fprintf(w, "Probe outcomes:\t%s\n", discovery.FormatProbeCounts(state.ProbeOutcomes))
+	fmt.Fprintf(w, "Refused ranges:\t%s\n", strings.Join(state.RefusedRanges, ", "))
+	fmt.Fprintf(w, "Candidates:\t%d/%d\n", state.Candidates, state.QuorumRequired)
+	fmt.Fprintf(w, "Missing members:\t%s\n", strings.Join(state.QuorumMissing, ", "))
+	fmt.Fprintf(w, "Rejected:\t%s\n", strings.Join(state.Rejected, "; "))
+	fmt.Fprintf(w, "Leader:\t%s (%s)\n", state.Leader, state.LeaderHostname)
+	fmt.Fprintf(w, "Epoch:\t%d (%s)\n", state.Epoch, state.EpochLeader)
+	fmt.Fprintf(w, "Demoted leaders:\t%s\n", strings.Join(state.DemotedLeaders, ", "))
+	fmt.Fprintf(w, "View:\t%s\n", strings.Join(state.View, ", "))
+	fmt.Fprintf(w, "View conflicts:\t%s\n", strings.Join(state.ViewConflicts, "; "))
+	fmt.Fprintf(w, "Is leader:\t%t\n", state.IsLeader)
+	if last := state.LastElection; last != nil {
+		fmt.Fprintf(w, "Last election:\t%s leader %s, %d times since %s (see status --audit)\n", last.Strategy,
+			last.Leader, last.Count, last.Time.Format(time.RFC3339))
+	}
+	fmt.Fprintf(w, "Last error:\t%s\n", state.LastError)
+	fmt.Fprintf(w, "Version:\t%s\n", state.Version)
+	fmt.Fprintf(w, "Started at:\t%s\n", state.StartedAt.Format(time.RFC3339))
+	fmt.Fprintf(w, "Updated at:\t%s\n", state.UpdatedAt.Format(time.RFC3339))
+	return w.Flush()
+}
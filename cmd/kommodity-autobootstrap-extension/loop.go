@@ -0,0 +1,508 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"time"
+
+	talosclient "github.com/siderolabs/talos/pkg/machinery/client"
+	"go.uber.org/zap"
+
+	"github.com/kommodity/talos-auto-bootstrap/internal/config"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/bootstrap"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/election"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/status"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/talosconfig"
+)
+
+// bootstrapLoop handles discovery, election, and bootstrap.
+type bootstrapLoop struct {
+	client   *talosclient.Client
+	cfg      *config.Config
+	exporter *talosconfig.Exporter
+	recorder *status.Recorder
+	strategy election.Strategy
+	failover *election.Failover
+
+	// netPolicy selects the interface used for discovery
+	netPolicy discovery.InterfacePolicy
+
+	// scanRanges are the configured ranges scanned instead of the local network
+	scanRanges []discovery.ScanRange
+
+	// peerCache remembers known peers between scans
+	peerCache *discovery.PeerCache
+
+	// scanOpts are reused across scans, so adaptive timeouts keep their samples
+	scanOpts discovery.ScanOptions
+
+	// peers keeps connections to the known control plane peers open
+	peers *discovery.PeerPool
+
+	// eligibility decides which control plane nodes take part in elections
+	eligibility election.Eligibility
+
+	// quorum is the quorum required before bootstrapping
+	quorum election.QuorumRule
+
+	// token is the current election epoch and the leader that claimed it
+	token election.Token
+
+	// localPriority is the election priority from the local machine config
+	localPriority int
+}
+
+// run is the main loop that handles discovery, election, and bootstrap.
+func (l *bootstrapLoop) run(ctx context.Context) error {
+	cfg := l.cfg
+	backoff := 5 * time.Second
+	coordinator := bootstrap.NewCoordinator(l.client, l.peerClient, cfg.PreBootstrapDelay, cfg.DryRun)
+	if cfg.DryRun {
+		zap.L().Warn("dry-run mode enabled, the cluster will not be bootstrapped")
+	}
+
+	l.token = tokenFromState(l.recorder.State())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		// Check if cluster is already bootstrapped
+		bootstrapped, err := bootstrap.IsClusterBootstrapped(ctx, l.client)
+		if err == nil && bootstrapped {
+			l.recorder.Update(func(s *status.State) { s.Phase = status.PhaseBootstrapped })
+			zap.L().Info("cluster already bootstrapped")
+			return nil
+		}
+
+		l.recorder.Update(func(s *status.State) { s.Phase = status.PhaseDiscovering })
+
+		// Get network information using filesystem/net package
+		// (COSI access is not available to extensions)
+		netInfo, err := discovery.GetNetworkInfoWithPolicy(l.netPolicy)
+		if err != nil {
+			zap.L().Warn("failed to get network info, retrying", zap.Error(err))
+			time.Sleep(backoff)
+			continue
+		}
+
+		zap.L().Info("network discovered",
+			zap.String("localIP", netInfo.LocalIP.String()),
+			zap.String("cidr", netInfo.CIDR.String()),
+			zap.String("interface", netInfo.LinkName),
+			zap.String("gateway", netInfo.Gateway.String()))
+
+		// Get local node information
+		localNode, err := discovery.GetLocalNodeInfo(ctx, l.client, netInfo.LocalIP)
+		if err != nil {
+			zap.L().Warn("failed to get local node info, retrying", zap.Error(err))
+			time.Sleep(backoff)
+			continue
+		}
+		localNode.Priority = l.localPriority
+
+		// Scan the configured ranges (or the local network) for peer Talos nodes
+		peers, err := l.scanPeers(ctx, netInfo, *localNode)
+		if err != nil {
+			l.recorder.Update(func(s *status.State) { s.LastError = err.Error() })
+			zap.L().Warn("network scan failed, retrying", zap.Error(err))
+			time.Sleep(backoff)
+			continue
+		}
+
+		l.recorder.Update(func(s *status.State) {
+			s.LocalIP = netInfo.LocalIP.String()
+			s.PeersFound = len(peers)
+			s.Peers = slices.Clone(peers)
+		})
+
+		zap.L().Info("peer discovery complete", zap.Int("peers_found", len(peers)))
+		for _, peer := range peers {
+			zap.L().Debug("discovered peer",
+				zap.String("ip", peer.IP.String()),
+				zap.String("hostname", peer.Hostname),
+				zap.Bool("controlplane", peer.IsControlPlane),
+				zap.Int("priority", peer.Priority),
+				zap.String("source", string(peer.Source)),
+				zap.String("version", peer.Version),
+				zap.String("node_id", peer.NodeID),
+				zap.String("cluster_id", peer.ClusterID),
+				zap.Stringers("addresses", peer.Addresses))
+		}
+
+		// Only eligible control plane nodes take part in quorum and election
+		peers, eligible := l.filterEligible(*localNode, peers)
+		if !eligible {
+			l.sleepJittered(cfg.ScanInterval)
+			continue
+		}
+
+		// Check if quorum is reached
+		allNodes := append(peers, *localNode)
+		if !l.quorumReached(allNodes) {
+			l.sleepJittered(cfg.ScanInterval)
+			continue
+		}
+
+		// Perform leader election, excluding demoted leaders
+		result := l.failover.Elect(l.strategy, *localNode, peers)
+		if len(cfg.QuorumExpectedMembers) == 0 && cfg.QuorumNodes == 1 && len(result.Candidates) > 1 {
+			zap.L().Warn("quorum is 1 but multiple control plane nodes were discovered",
+				zap.Int("candidates", len(result.Candidates)))
+		}
+		l.recordElection(result)
+		l.auditElection(result)
+		zap.L().Info("leader election complete",
+			zap.String("leader", result.Leader.IP.String()),
+			zap.String("leader_hostname", result.Leader.Hostname),
+			zap.Bool("is_leader", result.IsLeader),
+			zap.String("strategy", result.Strategy),
+			zap.Int("candidates", len(result.Candidates)))
+
+		// Exchange election state with the other control plane nodes
+		others := l.failover.Peers(result.Candidates, localNode.IP, l.peerCache.Contains)
+		peerStates := l.readPeerStates(ctx, others)
+
+		// A dry-run leader stops without bootstrapping: its would-bootstrap
+		// phase stands in for a bootstrapped cluster, so it is not demoted
+		if peer, ok := status.WouldBootstrap(peerStates); cfg.DryRun && ok {
+			l.recorder.Update(func(s *status.State) {
+				s.Phase = status.PhaseFollower
+				s.IsLeader = false
+				s.LastError = ""
+			})
+			zap.L().Info("dry run complete, another node would have bootstrapped the cluster",
+				zap.String("leader", peer.String()))
+			return nil
+		}
+
+		if l.failover.Observe(*result.Leader) {
+			l.recordDemotion(*result.Leader, result.IsLeader)
+			continue
+		}
+
+		if l.adoptDemotions(peerStates) {
+			continue
+		}
+		if !l.updateEpoch(result, localNode.IP, peerStates) {
+			continue
+		}
+
+		if !result.IsLeader {
+			zap.L().Info("not elected as leader, waiting for bootstrap",
+				zap.Duration("leader_elected_for", l.failover.StalledFor()))
+			l.sleepJittered(cfg.FollowerCheckInterval)
+			continue
+		}
+
+		// Refuse to bootstrap while candidates disagree on who takes part
+		if !l.viewsConverged(result, peerStates) {
+			l.sleepJittered(cfg.ScanInterval)
+			continue
+		}
+
+		// This node is the leader - execute bootstrap
+		zap.L().Info("elected as leader, initiating bootstrap")
+		err = coordinator.SafeBootstrap(ctx, others, l.fence(others))
+		var fenced *fencedError
+		if errors.As(err, &fenced) {
+			l.stepDown(localNode.IP, fenced)
+			continue
+		}
+		if errors.Is(err, bootstrap.ErrAlreadyBootstrapped) {
+			l.recorder.Update(func(s *status.State) {
+				s.Phase = status.PhaseBootstrapped
+				s.IsLeader = false
+				s.LastError = ""
+			})
+			zap.L().Info("cluster was bootstrapped by another node, not bootstrapping", zap.Error(err))
+			return nil
+		}
+		if err != nil {
+			l.recorder.Update(func(s *status.State) { s.LastError = err.Error() })
+			zap.L().Error("bootstrap failed, retrying", zap.Error(err))
+			time.Sleep(backoff)
+			// Exponential backoff with cap
+			backoff = min(backoff*2, cfg.MaxBackoff)
+			continue
+		}
+
+		if cfg.DryRun {
+			l.recorder.Update(func(s *status.State) {
+				s.Phase = status.PhaseWouldBootstrap
+				s.LastError = ""
+			})
+			zap.L().Info("dry run complete, this node would have bootstrapped the cluster")
+			return nil
+		}
+
+		l.recorder.Update(func(s *status.State) {
+			s.Phase = status.PhaseBootstrapped
+			s.LastError = ""
+		})
+		zap.L().Info("bootstrap successful")
+
+		l.exportTalosconfig(ctx, result.Candidates)
+
+		return nil
+	}
+}
+
+// filterEligible removes ineligible control plane peers and records the
+// rejections. It reports whether the local node itself is eligible.
+func (l *bootstrapLoop) filterEligible(localNode discovery.DiscoveredNode,
+	peers []discovery.DiscoveredNode) ([]discovery.DiscoveredNode, bool) {
+
+	peers, rejected := l.eligibility.FilterEligible(localNode, peers)
+
+	localReasons := l.eligibility.Check(localNode, localNode.Version)
+	if len(localReasons) > 0 {
+		rejected = append(rejected, election.Rejection{Node: localNode, Reasons: localReasons})
+	}
+
+	rejections := make([]string, 0, len(rejected))
+	for _, r := range rejected {
+		rejections = append(rejections, r.String())
+		zap.L().Info("control plane node is not eligible for election",
+			zap.String("ip", r.Node.IP.String()),
+			zap.String("hostname", r.Node.Hostname),
+			zap.Strings("reasons", r.Reasons))
+	}
+
+	l.recorder.Update(func(s *status.State) {
+		s.Rejected = rejections
+		if len(localReasons) > 0 {
+			s.Phase = status.PhaseIneligible
+		}
+	})
+
+	if len(localReasons) > 0 {
+		zap.L().Warn("local node is not eligible for election, waiting", zap.Strings("reasons", localReasons))
+		return peers, false
+	}
+
+	return peers, true
+}
+
+// quorumReached checks quorum with either the expected-member list or the
+// control plane node count, and records and logs the outcome.
+func (l *bootstrapLoop) quorumReached(nodes []discovery.DiscoveredNode) bool {
+	quorum := l.quorum.Check(nodes)
+	l.recorder.Update(func(s *status.State) {
+		s.QuorumRequired = quorum.Required
+		s.QuorumMissing = quorum.Missing
+		if !quorum.Reached {
+			s.Phase = status.PhaseWaitingQuorum
+		}
+	})
+
+	switch {
+	case len(l.quorum.Members) == 0 && !quorum.Reached:
+		zap.L().Info("quorum not reached, waiting",
+			zap.Int("found", len(nodes)),
+			zap.Int("required", quorum.Required))
+	case !quorum.Reached:
+		zap.L().Info("expected members quorum not reached, waiting",
+			zap.Strings("present", quorum.Present),
+			zap.Strings("missing", quorum.Missing),
+			zap.Int("required", quorum.Required))
+	case len(quorum.Missing) > 0:
+		zap.L().Warn("expected members quorum reached with members missing",
+			zap.Strings("missing", quorum.Missing))
+	}
+
+	return quorum.Reached
+}
+
+// scanPeers scans the configured ranges (or the local network) for peer
+// Talos nodes. With ScanStopEarly, the scan stops as soon as the peers found
+// complete the expected control plane set.
+func (l *bootstrapLoop) scanPeers(ctx context.Context, netInfo *discovery.NetworkInfo,
+	localNode discovery.DiscoveredNode) ([]discovery.DiscoveredNode, error) {
+
+	cfg := l.cfg
+
+	ranges, refused := discovery.LimitScanRanges(
+		discovery.ScanRanges(netInfo, l.scanRanges, cfg.ScanConnectedSubnets),
+		cfg.ScanMaxHosts, cfg.ScanOversizedRanges)
+	var refusedRanges []string
+	for _, r := range refused {
+		zap.L().Warn("not scanning oversized range",
+			zap.Stringer("range", r), zap.Int("hosts", r.HostCount()), zap.Int("max_hosts", cfg.ScanMaxHosts))
+		refusedRanges = append(refusedRanges, r.String())
+	}
+
+	// Without any range to scan, no peer can ever be found
+	var refusedAll string
+	if len(ranges) == 0 && len(refused) > 0 {
+		refusedAll = fmt.Sprintf("nothing to scan: every range has more than scanMaxHosts (%d) hosts, "+
+			"configure smaller scan ranges or set scanOversizedRanges to sample", cfg.ScanMaxHosts)
+		zap.L().Error(refusedAll, zap.Strings("refused_ranges", refusedRanges))
+	}
+
+	// Between full sweeps, only known peers and recently active neighbors are probed
+	opts := l.scanOpts
+	full := l.peerCache.SweepDue(cfg.FullScanInterval)
+	opts.Known = l.peerCache.Known(ranges)
+	if cfg.ScanNeighborSeeding {
+		opts.Seed = discovery.NeighborAddrs()
+	}
+	opts.SeedOnly = !full
+	opts.Pool = l.peers
+	opts.Stats = discovery.NewScanStats(func(ip netip.Addr, err *discovery.ProbeError) {
+		zap.L().Debug("probe failed", zap.String("ip", ip.String()),
+			zap.String("outcome", string(err.Outcome)), zap.Error(err.Err))
+	})
+	zap.L().Debug("scanning for peers", zap.Stringers("ranges", ranges), zap.Bool("full_sweep", full))
+
+	var (
+		done         func([]discovery.DiscoveredNode) bool
+		stoppedEarly bool
+	)
+	if cfg.ScanStopEarly {
+		done = func(peers []discovery.DiscoveredNode) bool {
+			zap.L().Debug("peer found during scan", zap.Int("peers_found", len(peers)))
+			stoppedEarly = l.scanComplete(localNode, peers)
+			return stoppedEarly
+		}
+	}
+
+	peers, err := discovery.ScanRangesUntil(ctx, ranges, append(netInfo.LocalAddrs, netInfo.LocalIP),
+		opts, done)
+	if err != nil {
+		return nil, err
+	}
+
+	if stoppedEarly {
+		zap.L().Info("expected control plane set complete, stopped scan early")
+	}
+
+	l.recorder.Update(func(s *status.State) {
+		s.ProbeOutcomes = opts.Stats.Counts()
+		s.RefusedRanges = refusedRanges
+		if refusedAll != "" {
+			s.LastError = refusedAll
+		}
+	})
+	if len(peers) == 0 {
+		zap.L().Info("no peers discovered", zap.Stringer("probe_outcomes", opts.Stats))
+	} else {
+		zap.L().Debug("probe outcomes", zap.Stringer("probe_outcomes", opts.Stats))
+	}
+
+	// A sweep that stopped early did not cover the ranges completely
+	if err := l.peerCache.Update(peers, full && !stoppedEarly); err != nil {
+		zap.L().Warn("failed to persist peer cache", zap.Error(err))
+	}
+
+	// Connections to control plane peers are kept for the next scan and peer queries
+	var controlPlanes []netip.Addr
+	for _, peer := range peers {
+		if peer.IsControlPlane {
+			controlPlanes = append(controlPlanes, peer.IP)
+		}
+	}
+	l.peers.Retain(controlPlanes)
+
+	return peers, nil
+}
+
+// sleepJittered waits for d plus a random delay of up to ScanJitter, so
+// nodes booting together do not scan in lockstep.
+func (l *bootstrapLoop) sleepJittered(d time.Duration) {
+	if l.cfg.ScanJitter > 0 {
+		d += rand.N(l.cfg.ScanJitter)
+	}
+	time.Sleep(d)
+}
+
+// scanComplete reports whether the peers found so far complete the expected
+// control plane set: all expected members, or else QuorumNodes eligible
+// control plane nodes including the local node.
+func (l *bootstrapLoop) scanComplete(localNode discovery.DiscoveredNode, peers []discovery.DiscoveredNode) bool {
+	eligible, _ := l.eligibility.FilterEligible(localNode, peers)
+	nodes := append(eligible, localNode)
+
+	// Required 0 means all expected members
+	all := l.quorum
+	all.MembersRequired = 0
+
+	return all.Check(nodes).Reached
+}
+
+// recordDemotion logs and records a stalled leader that was demoted.
+func (l *bootstrapLoop) recordDemotion(leader discovery.DiscoveredNode, isLocal bool) {
+	if isLocal {
+		zap.L().Warn("local node failed to bootstrap the cluster in time, stepping down as leader",
+			zap.Duration("timeout", l.cfg.LeaderStallTimeout))
+	} else {
+		zap.L().Warn("elected leader did not bootstrap the cluster in time, demoting it",
+			zap.String("leader", leader.IP.String()),
+			zap.String("leader_hostname", leader.Hostname),
+			zap.Duration("timeout", l.cfg.LeaderStallTimeout))
+	}
+
+	demoted := l.failover.Demoted()
+	l.recorder.Update(func(s *status.State) {
+		s.DemotedLeaders = addrStrings(demoted)
+	})
+}
+
+// recordElection stores the outcome of an election in the local bootstrap state.
+func (l *bootstrapLoop) recordElection(result *election.ElectionResult) {
+	l.recorder.Update(func(s *status.State) {
+		s.Phase = status.PhaseFollower
+		if result.IsLeader {
+			s.Phase = status.PhaseBootstrapping
+		}
+		s.Candidates = len(result.Candidates)
+		s.QuorumRequired = l.quorum.Required()
+		s.Leader = result.Leader.IP.String()
+		s.LeaderHostname = result.Leader.Hostname
+		s.IsLeader = result.IsLeader
+		s.View = election.NewView(result.Candidates).Strings()
+	})
+}
+
+// auditElection appends the election decision and its inputs to the audit log.
+func (l *bootstrapLoop) auditElection(result *election.ElectionResult) {
+	state := l.recorder.State()
+
+	record := status.AuditRecord{
+		Strategy:       result.Strategy,
+		QuorumRule:     l.quorum.String(),
+		Candidates:     slices.Clone(result.Candidates),
+		Rejected:       state.Rejected,
+		Demoted:        state.DemotedLeaders,
+		Leader:         result.Leader.IP.String(),
+		LeaderHostname: result.Leader.Hostname,
+		IsLeader:       result.IsLeader,
+	}
+
+	l.recorder.Audit(record)
+}
+
+// exportTalosconfig exports the operator talosconfig if enabled.
+// A failed export must not fail an otherwise successful bootstrap.
+func (l *bootstrapLoop) exportTalosconfig(ctx context.Context, candidates []discovery.DiscoveredNode) {
+	if l.exporter == nil {
+		return
+	}
+
+	endpoints := make([]netip.Addr, 0, len(candidates))
+	for _, candidate := range candidates {
+		endpoints = append(endpoints, candidate.IP)
+	}
+
+	if err := l.exporter.Export(ctx, endpoints); err != nil {
+		zap.L().Error("failed to export talosconfig", zap.Error(err))
+	}
+}
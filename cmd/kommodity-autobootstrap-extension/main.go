@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -21,6 +22,8 @@ import (
 	creds "github.com/kommodity/talos-auto-bootstrap/pkg/credentials"
 	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
 	"github.com/kommodity/talos-auto-bootstrap/pkg/election"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/status"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/talosconfig"
 )
 
 // Version is set at build time.
@@ -34,7 +37,8 @@ const (
 	ApidPort = "50000"
 
 	// EtcdSecretsPath is the path to etcd secrets directory.
-	// This directory only exists on control plane nodes.
+	// This directory only exists on control plane nodes, and is used to
+	// corroborate the machine type from the machine config.
 	EtcdSecretsPath = "/system/secrets/etcd"
 )
 
@@ -43,17 +47,45 @@ func main() {
 		syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	// Without a subcommand the binary runs as the extension service
+	command, args := commandRun, os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	if command == commandRun {
+		if len(args) > 0 {
+			fmt.Fprint(os.Stderr, usage)
+			fmt.Fprintf(os.Stderr, "error: unexpected arguments: %s\n", strings.Join(args, " "))
+			os.Exit(1)
+		}
+		serve(ctx)
+		return
+	}
+
+	if err := runCommand(ctx, command, args); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// serve runs the extension service until the cluster is bootstrapped.
+func serve(ctx context.Context) {
 	logger := logging.NewLogger()
 	zap.ReplaceGlobals(logger)
 
 	zap.L().Info("starting talos-auto-bootstrap", zap.String("version", Version))
 
-	cfg, err := config.Load()
+	cfg, err := loadConfig()
 	if err != nil {
 		zap.L().Fatal("failed to load config", zap.Error(err))
 	}
 
-	if err := run(ctx, cfg); err != nil {
+	recorder := status.NewRecorder(cfg.StatusDir, Version)
+	recorder.Update(func(s *status.State) { s.DryRun = cfg.DryRun })
+
+	if err := run(ctx, cfg, recorder); err != nil {
+		recorder.Update(func(s *status.State) { s.LastError = err.Error() })
 		zap.L().Fatal("bootstrap service failed", zap.Error(err))
 	}
 
@@ -61,39 +93,92 @@ func main() {
 	os.Exit(0)
 }
 
-func run(ctx context.Context, cfg *config.Config) error {
-	// Check if this is a control plane node using filesystem
-	// (etcd secrets directory only exists on control plane nodes)
-	if !isControlPlane() {
-		zap.L().Info("worker node detected (no etcd secrets), exiting")
+// loadConfig loads and validates the configuration. Validation happens
+// before any disk mount or network activity.
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// validateConfig validates cfg and logs the warnings about risky settings.
+func validateConfig(cfg *config.Config) error {
+	warnings, err := cfg.Validate()
+	for _, warning := range warnings {
+		zap.L().Warn("risky configuration", zap.String("warning", warning))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	return nil
+}
+
+func run(ctx context.Context, cfg *config.Config, recorder *status.Recorder) error {
+	// Determine the machine role from the machine config on the STATE partition
+	// (the etcd secrets directory is only used as a corroborating signal)
+	zap.L().Info("reading machine config from STATE partition")
+	machineConfig, controlPlane, err := determineRole(ctx, cfg.RoleWaitTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to determine machine role: %w", err)
+	}
+
+	defer machineConfig.CA.Key.Wipe()
+
+	if !controlPlane {
+		recorder.Update(func(s *status.State) { s.Phase = status.PhaseWorker })
+		zap.L().Info("worker node detected, exiting")
 		return nil
 	}
 
-	zap.L().Info("control plane node detected, starting bootstrap process")
+	zap.L().Info("control plane node detected, starting bootstrap process",
+		zap.String("cluster_name", machineConfig.ClusterName),
+		zap.String("control_plane_endpoint", machineConfig.ControlPlaneEndpoint),
+		zap.Strings("documents", machineConfig.Documents))
 
 	// Get network info first to determine local IP for apid connection.
 	// apid's TLS certificate is issued for the node's IP, so we must connect
 	// using the actual IP (not localhost) for certificate validation to pass.
-	netInfo, err := discovery.GetNetworkInfo()
+	netPolicy, err := interfacePolicy(cfg, machineConfig.ControlPlaneEndpoint)
+	if err != nil {
+		return err
+	}
+
+	netInfo, err := discovery.GetNetworkInfoWithPolicy(netPolicy)
 	if err != nil {
 		return fmt.Errorf("failed to get network info: %w", err)
 	}
 
+	zap.L().Info("selected network interface",
+		zap.String("interface", netInfo.LinkName),
+		zap.String("selection", cfg.InterfaceSelection))
+
+	recorder.Update(func(s *status.State) { s.LocalIP = netInfo.LocalIP.String() })
+
 	apidEndpoint := net.JoinHostPort(netInfo.LocalIP.String(), ApidPort)
 	zap.L().Info("resolved apid endpoint", zap.String("endpoint", apidEndpoint))
 
-	// Read machine CA from the STATE partition
-	zap.L().Info("reading machine CA from STATE partition")
-	machineCA, err := creds.ReadCAFromStatePartition()
+	// Generate TLS config with os:admin credentials from the machine CA.
+	// The CA key is not needed afterwards and is wiped right after issuing.
+	zap.L().Info("generating admin TLS credentials from machine CA")
+	tlsConfig, err := creds.GenerateTLSConfig(machineConfig.CA.Crt, machineConfig.CA.Key)
 	if err != nil {
-		return fmt.Errorf("failed to read machine CA: %w", err)
+		return fmt.Errorf("failed to generate TLS config: %w", err)
 	}
 
-	// Generate TLS config with os:admin credentials from the machine CA
-	zap.L().Info("generating admin TLS credentials from machine CA")
-	tlsConfig, err := creds.GenerateTLSConfig(machineCA.Crt, machineCA.Key)
+	// The talosconfig certificate is issued now, so the CA key can be wiped
+	// instead of being kept around until the bootstrap completes.
+	exporter, err := newTalosconfigExporter(cfg, machineConfig)
+	machineConfig.CA.Key.Wipe()
 	if err != nil {
-		return fmt.Errorf("failed to generate TLS config: %w", err)
+		return fmt.Errorf("failed to prepare talosconfig export: %w", err)
 	}
 
 	// Wait for apid with TLS authentication
@@ -106,11 +191,47 @@ func run(ctx context.Context, cfg *config.Config) error {
 	// Check if cluster is already bootstrapped
 	bootstrapped, err := bootstrap.IsClusterBootstrapped(ctx, client)
 	if err == nil && bootstrapped {
+		recorder.Update(func(s *status.State) { s.Phase = status.PhaseBootstrapped })
 		zap.L().Info("cluster already bootstrapped, exiting")
 		return nil
 	}
 
-	return runBootstrapLoop(ctx, client, cfg)
+	strategy, err := election.NewStrategy(cfg.ElectionStrategy, cfg.ElectionPriorities)
+	if err != nil {
+		return err
+	}
+
+	ranges, err := scanRanges(cfg)
+	if err != nil {
+		return err
+	}
+
+	peerCache, err := discovery.NewPeerCache(cfg.PeerCachePath)
+	if err != nil {
+		zap.L().Warn("failed to load peer cache, starting with a full sweep", zap.Error(err))
+	}
+
+	peers := discovery.NewPeerPool(tlsConfig)
+	defer peers.Close()
+
+	loop := &bootstrapLoop{
+		client:        client,
+		cfg:           cfg,
+		netPolicy:     netPolicy,
+		scanRanges:    ranges,
+		peerCache:     peerCache,
+		scanOpts:      scanOptions(cfg),
+		peers:         peers,
+		exporter:      exporter,
+		recorder:      recorder,
+		strategy:      strategy,
+		failover:      election.NewFailover(cfg.LeaderStallTimeout),
+		eligibility:   eligibility(cfg),
+		quorum:        quorumRule(cfg),
+		localPriority: discovery.ParsePriority(machineConfig.NodeLabels, machineConfig.NodeAnnotations),
+	}
+
+	return loop.run(ctx)
 }
 
 // waitForApid waits for apid to become available and connects with TLS credentials.
@@ -138,108 +259,78 @@ func waitForApid(ctx context.Context, tlsConfig *tls.Config, endpoint string) (*
 	}
 }
 
-// isControlPlane checks if the current node is a control plane node.
-// It uses a filesystem-based check: the etcd secrets directory only exists
-// on control plane nodes.
-func isControlPlane() bool {
-	_, err := os.Stat(EtcdSecretsPath)
-	return err == nil
-}
-
-// runBootstrapLoop is the main loop that handles discovery, election, and bootstrap.
-func runBootstrapLoop(ctx context.Context, client *talosclient.Client, cfg *config.Config) error {
-	backoff := 5 * time.Second
-	coordinator := bootstrap.NewCoordinator(client, cfg.PreBootstrapDelay)
+// determineRole reads the machine config and decides whether this node is a
+// control plane node based on machine.type. The etcd secrets directory is only
+// used to corroborate the result, or as a fallback when machine.type is empty.
+// While the role cannot be determined, it retries until waitTimeout elapses.
+func determineRole(ctx context.Context, waitTimeout time.Duration) (*creds.MachineConfig, bool, error) {
+	deadline := time.Now().Add(waitTimeout)
 
 	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
+		machineConfig, err := creds.ReadMachineConfigFromStatePartition()
+		secretsPresent := etcdSecretsPresent()
+
+		switch {
+		case err != nil:
+			err = fmt.Errorf("failed to read machine CA: %w", err)
+		case machineConfig.MachineType != "":
+			controlPlane := machineConfig.IsControlPlane()
+			switch {
+			case controlPlane && !secretsPresent:
+				// Talos may not have created the directory yet
+				zap.L().Debug("etcd secrets directory not present yet on control plane node")
+			case !controlPlane && secretsPresent:
+				zap.L().Warn("etcd secrets directory present on worker node, trusting machine config",
+					zap.String("machine_type", machineConfig.MachineType))
+			}
+			return machineConfig, controlPlane, nil
+		case secretsPresent:
+			zap.L().Info("machine.type not set, etcd secrets directory indicates control plane")
+			return machineConfig, true, nil
 		default:
+			machineConfig.CA.Key.Wipe()
+			err = fmt.Errorf("machine.type not set and etcd secrets directory not present")
 		}
 
-		// Check if cluster is already bootstrapped
-		bootstrapped, err := bootstrap.IsClusterBootstrapped(ctx, client)
-		if err == nil && bootstrapped {
-			zap.L().Info("cluster already bootstrapped")
-			return nil
+		if !time.Now().Before(deadline) {
+			return nil, false, err
 		}
 
-		// Get network information using filesystem/net package
-		// (COSI access is not available to extensions)
-		netInfo, err := discovery.GetNetworkInfo()
-		if err != nil {
-			zap.L().Warn("failed to get network info, retrying", zap.Error(err))
-			time.Sleep(backoff)
-			continue
-		}
+		zap.L().Info("machine role not yet determinable, waiting", zap.Error(err))
 
-		zap.L().Info("network discovered",
-			zap.String("localIP", netInfo.LocalIP.String()),
-			zap.String("cidr", netInfo.CIDR.String()),
-			zap.String("gateway", netInfo.Gateway.String()))
-
-		// Scan CIDR for peer Talos nodes
-		peers, err := discovery.ScanCIDRForTalosNodes(ctx, netInfo.CIDR,
-			netInfo.LocalIP, cfg.ScanTimeout, cfg.ScanConcurrency)
-		if err != nil {
-			zap.L().Warn("network scan failed, retrying", zap.Error(err))
-			time.Sleep(backoff)
-			continue
-		}
-
-		zap.L().Info("peer discovery complete", zap.Int("peers_found", len(peers)))
-		for _, peer := range peers {
-			zap.L().Debug("discovered peer",
-				zap.String("ip", peer.IP.String()),
-				zap.String("hostname", peer.Hostname),
-				zap.Bool("controlplane", peer.IsControlPlane))
-		}
-
-		// Get local node information
-		localNode, err := discovery.GetLocalNodeInfo(ctx, client, netInfo.LocalIP)
-		if err != nil {
-			zap.L().Warn("failed to get local node info, retrying", zap.Error(err))
-			time.Sleep(backoff)
-			continue
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-time.After(5 * time.Second):
 		}
+	}
+}
 
-		// Check if quorum is reached
-		allNodes := append(peers, *localNode)
-		if !election.QuorumReached(allNodes, cfg.QuorumNodes) {
-			zap.L().Info("quorum not reached, waiting",
-				zap.Int("found", len(peers)+1),
-				zap.Int("required", cfg.QuorumNodes))
-			time.Sleep(cfg.ScanInterval)
-			continue
-		}
+// etcdSecretsPresent checks whether the etcd secrets directory exists.
+// Talos creates this directory on control plane nodes only, but it may
+// appear after the extension has started.
+func etcdSecretsPresent() bool {
+	_, err := os.Stat(EtcdSecretsPath)
+	return err == nil
+}
 
-		// Perform leader election
-		result := election.ElectLeader(*localNode, peers)
-		zap.L().Info("leader election complete",
-			zap.String("leader", result.Leader.IP.String()),
-			zap.String("leader_hostname", result.Leader.Hostname),
-			zap.Bool("is_leader", result.IsLeader),
-			zap.Int("candidates", len(result.Candidates)))
-
-		if !result.IsLeader {
-			zap.L().Info("not elected as leader, waiting for bootstrap")
-			time.Sleep(cfg.FollowerCheckInterval)
-			continue
-		}
+// newTalosconfigExporter issues the client certificate for the operator talosconfig
+// when the export is enabled. It returns nil if the export is disabled.
+func newTalosconfigExporter(cfg *config.Config, machineConfig *creds.MachineConfig) (*talosconfig.Exporter, error) {
+	if !cfg.TalosconfigExport {
+		return nil, nil
+	}
 
-		// This node is the leader - execute bootstrap
-		zap.L().Info("elected as leader, initiating bootstrap")
-		err = coordinator.SafeBootstrap(ctx)
-		if err != nil {
-			zap.L().Error("bootstrap failed, retrying", zap.Error(err))
-			time.Sleep(backoff)
-			// Exponential backoff with cap
-			backoff = min(backoff*2, cfg.MaxBackoff)
-			continue
-		}
+	zap.L().Info("issuing talosconfig credentials from machine CA",
+		zap.String("role", cfg.TalosconfigRole),
+		zap.Duration("validity", cfg.TalosconfigCertValidity))
 
-		zap.L().Info("bootstrap successful")
-		return nil
+	tlsConfig, err := creds.GenerateTLSConfigForRole(machineConfig.CA.Crt, machineConfig.CA.Key,
+		cfg.TalosconfigRole, cfg.TalosconfigCertValidity)
+	if err != nil {
+		return nil, err
 	}
+
+	return talosconfig.NewExporter(machineConfig.ClusterName, machineConfig.CA.Crt, tlsConfig,
+		cfg.TalosconfigPath, cfg.TalosconfigURL), nil
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/time/rate"
+
+	"github.com/kommodity/talos-auto-bootstrap/internal/config"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/election"
+)
+
+// eligibility returns the configured election eligibility filters.
+func eligibility(cfg *config.Config) election.Eligibility {
+	return election.Eligibility{
+		Stages:             cfg.EligibleStages,
+		RequireReady:       cfg.EligibleRequireReady,
+		MinVersion:         cfg.EligibleMinVersion,
+		MatchVersion:       cfg.EligibleMatchVersion,
+		EtcdStates:         cfg.EligibleEtcdStates,
+		ExcludeMaintenance: cfg.EligibleExcludeMaintenance,
+	}
+}
+
+// quorumRule returns the configured quorum rule.
+func quorumRule(cfg *config.Config) election.QuorumRule {
+	return election.QuorumRule{
+		Nodes:           cfg.QuorumNodes,
+		Members:         cfg.QuorumExpectedMembers,
+		MembersRequired: cfg.QuorumExpectedRequired,
+	}
+}
+
+// interfacePolicy returns the configured interface selection policy.
+// endpoint is the control plane endpoint used by the endpoint-route selection.
+func interfacePolicy(cfg *config.Config, endpoint string) (discovery.InterfacePolicy, error) {
+	return discovery.NewInterfacePolicy(cfg.InterfaceSelection, cfg.InterfaceNames, cfg.InterfaceCIDRs,
+		cfg.InterfaceExclude, endpoint)
+}
+
+// scanRanges returns the configured scan ranges.
+func scanRanges(cfg *config.Config) ([]discovery.ScanRange, error) {
+	ranges := make([]discovery.ScanRange, 0, len(cfg.ScanCIDRs))
+	for _, cidr := range cfg.ScanCIDRs {
+		r, err := discovery.ParseScanRange(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("scanCIDRs: %w", err)
+		}
+		ranges = append(ranges, r)
+	}
+
+	return ranges, nil
+}
+
+// scanOptions returns the options for scanning ranges for Talos nodes.
+// The adaptive timeouts and the rate limiter are created anew, so the
+// options should be reused across scans.
+func scanOptions(cfg *config.Config) discovery.ScanOptions {
+	opts := discovery.ScanOptions{
+		Timeout:             cfg.ScanTimeout,
+		Concurrency:         cfg.ScanConcurrency,
+		PreProbe:            cfg.ScanPreProbe,
+		PreProbeTimeout:     cfg.ScanPreProbeTimeout,
+		PreProbeConcurrency: cfg.ScanPreProbeConcurrency,
+	}
+
+	if cfg.ScanAdaptiveTimeouts {
+		opts.ConnectTimeouts = discovery.NewAdaptiveTimeouts(cfg.ScanMinTimeout)
+	}
+
+	if cfg.ScanRate > 0 {
+		opts.Limiter = rate.NewLimiter(rate.Limit(cfg.ScanRate), 1)
+	}
+
+	return opts
+}
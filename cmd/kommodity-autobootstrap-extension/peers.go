@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	talosclient "github.com/siderolabs/talos/pkg/machinery/client"
+	"go.uber.org/zap"
+
+	"github.com/kommodity/talos-auto-bootstrap/pkg/bootstrap"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/election"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/status"
+)
+
+// peerClient returns the pooled connection to peer, or the local apid client
+// (which proxies the request to peer) if peer is not pooled, e.g. a demoted
+// leader that was not found by the last scan.
+func (l *bootstrapLoop) peerClient(peer netip.Addr) *talosclient.Client {
+	if client, ok := l.peers.Client(peer); ok {
+		return client
+	}
+	return l.client
+}
+
+// readPeerStates reads the status published by each peer through apid.
+// Peers whose status cannot be read map to nil.
+func (l *bootstrapLoop) readPeerStates(ctx context.Context, peers []netip.Addr) map[netip.Addr]*status.State {
+	states := make(map[netip.Addr]*status.State, len(peers))
+	for _, peer := range peers {
+		state, err := status.ReadRemote(ctx, l.peerClient(peer), peer, l.recorder.Dir())
+		if err != nil {
+			l.peers.Reset(peer)
+			zap.L().Debug("failed to read peer state", zap.String("peer", peer.String()), zap.Error(err))
+		}
+		states[peer] = state
+	}
+	return states
+}
+
+// updateEpoch advances the local election epoch after an election, as
+// decided by election.UpdateEpoch. A leader whose claim was superseded steps
+// down; updateEpoch then returns false.
+func (l *bootstrapLoop) updateEpoch(result *election.ElectionResult, localIP netip.Addr,
+	peerStates map[netip.Addr]*status.State) bool {
+
+	token, action := election.UpdateEpoch(l.token, localIP, result.IsLeader, peerTokens(peerStates)...)
+	l.token = token
+
+	switch action {
+	case election.EpochFenced, election.EpochYield:
+		l.stepDown(localIP, &fencedError{token: token, action: action})
+		return false
+	case election.EpochClaim:
+		zap.L().Info("claimed election epoch", zap.Uint64("epoch", token.Epoch))
+	}
+
+	l.recorder.Update(func(s *status.State) {
+		s.Epoch = token.Epoch
+		s.EpochLeader = ""
+		if token.Leader.IsValid() {
+			s.EpochLeader = token.Leader.String()
+		}
+	})
+
+	return true
+}
+
+// fence returns a FenceFunc that re-reads the peers' epochs and reports
+// whether the local leader's token was superseded in the meantime.
+func (l *bootstrapLoop) fence(peers []netip.Addr) bootstrap.FenceFunc {
+	return func(ctx context.Context) error {
+		observed := peerTokens(l.readPeerStates(ctx, peers))
+		if latest, action := election.Fence(l.token, observed...); action != election.EpochKeep {
+			return &fencedError{token: latest, action: action}
+		}
+		return nil
+	}
+}
+
+// adoptDemotions demotes the peers that published that they stepped down,
+// e.g. after they were fenced. Without this, the other nodes would keep
+// electing a leader that no longer bootstraps. It reports whether any peer
+// was newly demoted, in which case the election must be repeated.
+func (l *bootstrapLoop) adoptDemotions(peerStates map[netip.Addr]*status.State) bool {
+	adopted := false
+	for peer, state := range peerStates {
+		if state == nil {
+			continue
+		}
+
+		demoted := make([]netip.Addr, 0, len(state.DemotedLeaders))
+		for _, leader := range state.DemotedLeaders {
+			if ip, err := netip.ParseAddr(leader); err == nil {
+				demoted = append(demoted, ip)
+			}
+		}
+
+		if l.failover.Adopt(peer, demoted) {
+			zap.L().Warn("peer stepped down as leader, demoting it", zap.String("peer", peer.String()))
+			adopted = true
+		}
+	}
+
+	if adopted {
+		demoted := l.failover.Demoted()
+		l.recorder.Update(func(s *status.State) {
+			s.DemotedLeaders = addrStrings(demoted)
+		})
+	}
+
+	return adopted
+}
+
+// fencedError reports that the local leader's token was superseded. It wraps
+// bootstrap.ErrFenced.
+type fencedError struct {
+	// token is the superseding token
+	token election.Token
+	// action is election.EpochFenced or election.EpochYield
+	action election.EpochAction
+}
+
+func (e *fencedError) Error() string {
+	if e.action == election.EpochYield {
+		return fmt.Sprintf("%s: lost the concurrent claim of epoch %d to %s", bootstrap.ErrFenced,
+			e.token.Epoch, e.token.Leader)
+	}
+	return fmt.Sprintf("%s: epoch %d was claimed by %s", bootstrap.ErrFenced, e.token.Epoch, e.token.Leader)
+}
+
+func (e *fencedError) Unwrap() error {
+	return bootstrap.ErrFenced
+}
+
+// stepDown makes the local leader step down after it was fenced and adopts
+// the superseding token. Fenced by a newer epoch, the local node is demoted so
+// it no longer considers itself leader in later elections; the demotion is
+// published in the status, where the peers adopt it. Having lost a concurrent
+// claim of the same epoch, it only steps down for this round, as its claim
+// raced a peer's rather than being stale.
+func (l *bootstrapLoop) stepDown(localIP netip.Addr, err *fencedError) {
+	l.token = err.token
+
+	if err.action == election.EpochYield {
+		zap.L().Warn("local leader lost a concurrent epoch claim, stepping down for this round", zap.Error(err))
+	} else {
+		zap.L().Warn("local leader was fenced, stepping down", zap.Error(err))
+		l.failover.Demote(localIP)
+	}
+
+	demoted := l.failover.Demoted()
+	token := l.token
+	l.recorder.Update(func(s *status.State) {
+		s.Phase = status.PhaseFenced
+		s.IsLeader = false
+		s.LastError = err.Error()
+		s.DemotedLeaders = addrStrings(demoted)
+		s.Epoch = token.Epoch
+		s.EpochLeader = token.Leader.String()
+	})
+}
+
+// viewsConverged compares the local candidate view with the views published
+// by the other candidates in their status, to detect a split brain before
+// bootstrapping. It reports whether bootstrap may proceed.
+func (l *bootstrapLoop) viewsConverged(result *election.ElectionResult,
+	peerStates map[netip.Addr]*status.State) bool {
+
+	local := election.NewView(result.Candidates)
+	peers := make(map[netip.Addr]election.View, len(local))
+	for _, candidate := range result.Candidates {
+		if candidate.IP == result.Leader.IP {
+			continue
+		}
+
+		peers[candidate.IP] = nil
+
+		state := peerStates[candidate.IP]
+		if state == nil {
+			continue
+		}
+
+		view, err := election.ParseView(state.View)
+		if err != nil || len(view) == 0 {
+			continue
+		}
+		peers[candidate.IP] = view
+	}
+
+	check, proceed := election.CheckViews(l.cfg.ViewCheck, local, peers)
+
+	conflicts := make([]string, 0, len(check.Divergences))
+	var unknown []string
+	for _, d := range check.Divergences {
+		conflicts = append(conflicts, describeDivergence(d))
+		if d.Unknown {
+			unknown = append(unknown, d.Peer.String())
+		}
+	}
+
+	l.recorder.Update(func(s *status.State) {
+		s.ViewConflicts = conflicts
+		if !proceed {
+			s.Phase = status.PhaseViewsDiverged
+		}
+	})
+
+	switch {
+	case check.Converged:
+		return true
+	case proceed:
+		zap.L().Warn("candidate views diverge, bootstrapping anyway", zap.Strings("conflicts", conflicts))
+		return true
+	case len(unknown) == len(check.Divergences):
+		// An unreadable status is not evidence of a split brain, but it blocks
+		// the bootstrap all the same, so it is reported separately
+		zap.L().Warn("cannot read the candidate views of peers: refusing to bootstrap until their status "+
+			"is readable (check that the extension runs on them with the same status directory, "+
+			"or set TALOS_AUTO_BOOTSTRAP_VIEW_CHECK=warn)",
+			zap.Strings("view", local.Strings()),
+			zap.Strings("unknown_views", unknown))
+		return false
+	}
+
+	zap.L().Warn("candidate views diverge, possible split brain: refusing to bootstrap until they converge",
+		zap.Strings("view", local.Strings()),
+		zap.Strings("conflicts", conflicts),
+		zap.Strings("unknown_views", unknown))
+
+	return false
+}
+
+// describeDivergence renders a view divergence for logs and the status.
+func describeDivergence(d election.ViewDivergence) string {
+	if d.Unknown {
+		return fmt.Sprintf("%s: view unknown", d.Peer)
+	}
+
+	var parts []string
+	if len(d.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("does not see %v", d.Missing))
+	}
+	if len(d.Extra) > 0 {
+		parts = append(parts, fmt.Sprintf("also sees %v", d.Extra))
+	}
+
+	return fmt.Sprintf("%s: %s", d.Peer, strings.Join(parts, ", "))
+}
+
+// tokenFromState returns the election token recorded in a state.
+func tokenFromState(state status.State) election.Token {
+	token := election.Token{Epoch: state.Epoch}
+	if leader, err := netip.ParseAddr(state.EpochLeader); err == nil {
+		token.Leader = leader
+	}
+	return token
+}
+
+// peerTokens returns the election tokens recorded by the peers.
+func peerTokens(states map[netip.Addr]*status.State) []election.Token {
+	tokens := make([]election.Token, 0, len(states))
+	for _, state := range states {
+		if state != nil {
+			tokens = append(tokens, tokenFromState(*state))
+		}
+	}
+	return tokens
+}
+
+// addrStrings converts IP addresses to strings.
+func addrStrings(addrs []netip.Addr) []string {
+	out := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		out = append(out, addr.String())
+	}
+	return out
+}
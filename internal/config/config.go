@@ -1,38 +1,278 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ConfigFileEnv is the environment variable that overrides the config file path.
+	ConfigFileEnv = "TALOS_AUTO_BOOTSTRAP_CONFIG_FILE"
+
+	// DefaultConfigFile is the default config file path, delivered through
+	// the ExtensionServiceConfig configFiles field. A missing file is ignored.
+	DefaultConfigFile = "/usr/local/etc/kommodity-autobootstrap/config.yaml"
+
+	// APIVersion is the supported config file apiVersion.
+	APIVersion = "autobootstrap.kommodity.io/v1alpha1"
+
+	// Kind is the supported config file kind.
+	Kind = "AutoBootstrapConfig"
 )
 
 // Config holds runtime configuration for the auto-bootstrap service.
 type Config struct {
 	// ScanInterval is the time between network discovery scans
-	ScanInterval time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_INTERVAL" default:"30s"`
+	ScanInterval time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_INTERVAL" yaml:"scanInterval" default:"30s"`
 
 	// FollowerCheckInterval is how often followers check bootstrap status
-	FollowerCheckInterval time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_FOLLOWER_CHECK_INTERVAL" default:"15s"`
+	FollowerCheckInterval time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_FOLLOWER_CHECK_INTERVAL" yaml:"followerCheckInterval" default:"15s"`
 
 	// QuorumNodes is the expected number of control plane nodes required for quorum
-	QuorumNodes int `envconfig:"TALOS_AUTO_BOOTSTRAP_QUORUM_NODES" default:"1"`
+	QuorumNodes int `envconfig:"TALOS_AUTO_BOOTSTRAP_QUORUM_NODES" yaml:"quorumNodes" default:"1"`
+
+	// QuorumExpectedMembers is an expected set of control plane node identities
+	// (hostnames, IPs or machine UUIDs). When set, it replaces QuorumNodes
+	QuorumExpectedMembers []string `envconfig:"TALOS_AUTO_BOOTSTRAP_QUORUM_EXPECTED_MEMBERS" yaml:"quorumExpectedMembers"`
+
+	// QuorumExpectedRequired is how many expected members must be present (0 means all)
+	QuorumExpectedRequired int `envconfig:"TALOS_AUTO_BOOTSTRAP_QUORUM_EXPECTED_REQUIRED" yaml:"quorumExpectedRequired" default:"0"`
 
 	// PreBootstrapDelay is the wait time before leader executes bootstrap
-	PreBootstrapDelay time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_PRE_BOOTSTRAP_DELAY" default:"10s"`
+	PreBootstrapDelay time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_PRE_BOOTSTRAP_DELAY" yaml:"preBootstrapDelay" default:"10s"`
 
 	// MaxBackoff is the maximum retry backoff duration
-	MaxBackoff time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_MAX_BACKOFF" default:"2m"`
+	MaxBackoff time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_MAX_BACKOFF" yaml:"maxBackoff" default:"2m"`
+
+	// InterfaceSelection selects the interface used for discovery on multi-homed nodes:
+	// first, name, cidr, default-route or endpoint-route
+	InterfaceSelection string `envconfig:"TALOS_AUTO_BOOTSTRAP_INTERFACE_SELECTION" yaml:"interfaceSelection" default:"first"`
+
+	// InterfaceNames are regular expressions matched against interface names (name selection)
+	InterfaceNames []string `envconfig:"TALOS_AUTO_BOOTSTRAP_INTERFACE_NAMES" yaml:"interfaceNames"`
+
+	// InterfaceCIDRs are the networks the selected address must belong to (cidr selection)
+	InterfaceCIDRs []string `envconfig:"TALOS_AUTO_BOOTSTRAP_INTERFACE_CIDRS" yaml:"interfaceCIDRs"`
+
+	// InterfaceExclude are interface name glob patterns that are never selected
+	// (by default container, CNI and Kubernetes service interfaces)
+	InterfaceExclude []string `envconfig:"TALOS_AUTO_BOOTSTRAP_INTERFACE_EXCLUDE" yaml:"interfaceExclude" default:"cni*,kube-ipvs*,cilium_*,docker*,flannel*,veth*,lxc*"`
+
+	// ScanCIDRs are the networks scanned for peers instead of the local network, each
+	// optionally followed by excluded subranges, e.g. "10.1.0.0/20!10.1.5.0/24"
+	ScanCIDRs []string `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_CIDRS" yaml:"scanCIDRs"`
+
+	// ScanConnectedSubnets also scans every subnet connected to a local interface
+	// that is not excluded by InterfaceExclude
+	ScanConnectedSubnets bool `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_CONNECTED_SUBNETS" yaml:"scanConnectedSubnets" default:"false"`
+
+	// ScanMaxHosts is the maximum number of hosts scanned per range
+	ScanMaxHosts int `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_MAX_HOSTS" yaml:"scanMaxHosts" default:"4096"`
+
+	// ScanOversizedRanges decides what happens to ranges with more than ScanMaxHosts
+	// hosts: refuse (skip the range) or sample (scan a different sample each sweep)
+	ScanOversizedRanges string `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_OVERSIZED_RANGES" yaml:"scanOversizedRanges" default:"refuse"`
 
 	// ScanTimeout is the timeout for probing each node during discovery
-	ScanTimeout time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_TIMEOUT" default:"2s"`
+	ScanTimeout time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_TIMEOUT" yaml:"scanTimeout" default:"2s"`
 
 	// ScanConcurrency is the maximum number of concurrent node probes
-	ScanConcurrency int `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_CONCURRENCY" default:"50"`
+	ScanConcurrency int `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_CONCURRENCY" yaml:"scanConcurrency" default:"50"`
+
+	// ScanPreProbe checks that the Talos API port accepts TCP connections before
+	// running the full Talos probe on an address
+	ScanPreProbe bool `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_PRE_PROBE" yaml:"scanPreProbe" default:"true"`
+
+	// ScanPreProbeTimeout is the TCP connect timeout of the pre-probe
+	ScanPreProbeTimeout time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_PRE_PROBE_TIMEOUT" yaml:"scanPreProbeTimeout" default:"500ms"`
+
+	// ScanPreProbeConcurrency is the maximum number of concurrent pre-probes
+	ScanPreProbeConcurrency int `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_PRE_PROBE_CONCURRENCY" yaml:"scanPreProbeConcurrency" default:"256"`
+
+	// ScanNeighborSeeding probes the addresses in the kernel's neighbor table first
+	ScanNeighborSeeding bool `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_NEIGHBOR_SEEDING" yaml:"scanNeighborSeeding" default:"true"`
+
+	// ScanAdaptiveTimeouts derives per-host pre-probe timeouts from the connect times
+	// observed to responding peers, bounded by ScanMinTimeout and ScanPreProbeTimeout
+	ScanAdaptiveTimeouts bool `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_ADAPTIVE_TIMEOUTS" yaml:"scanAdaptiveTimeouts" default:"true"`
+
+	// ScanMinTimeout is the lower bound of adaptive pre-probe timeouts
+	ScanMinTimeout time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_MIN_TIMEOUT" yaml:"scanMinTimeout" default:"250ms"`
+
+	// ScanRate is the maximum number of connection attempts per second across all
+	// ranges (0 is unlimited)
+	ScanRate int `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_RATE" yaml:"scanRate" default:"0"`
+
+	// ScanJitter is the maximum random delay added to each wait between scans, so
+	// nodes booting together do not scan in lockstep
+	ScanJitter time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_JITTER" yaml:"scanJitter" default:"5s"`
+
+	// ScanStopEarly stops a scan as soon as the peers found complete the expected
+	// control plane set (all expected members, or else QuorumNodes nodes)
+	ScanStopEarly bool `envconfig:"TALOS_AUTO_BOOTSTRAP_SCAN_STOP_EARLY" yaml:"scanStopEarly" default:"false"`
+
+	// FullScanInterval is the time between full sweeps of the scan ranges. In
+	// between, only known peers and the neighbor table entries are probed
+	FullScanInterval time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_FULL_SCAN_INTERVAL" yaml:"fullScanInterval" default:"2m"`
+
+	// PeerCachePath is the file the known peers are persisted to (empty disables persistence)
+	PeerCachePath string `envconfig:"TALOS_AUTO_BOOTSTRAP_PEER_CACHE_PATH" yaml:"peerCachePath" default:"/run/autobootstrap/peers.json"`
+
+	// RoleWaitTimeout is how long to wait for the machine role to become determinable
+	// (machine config readable with machine.type set, or etcd secrets present).
+	// Zero disables waiting.
+	RoleWaitTimeout time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_ROLE_WAIT_TIMEOUT" yaml:"roleWaitTimeout" default:"2m"`
+
+	// ElectionStrategy is the leader election strategy: boot-time, lowest-ip, hostname or priority
+	ElectionStrategy string `envconfig:"TALOS_AUTO_BOOTSTRAP_ELECTION_STRATEGY" yaml:"electionStrategy" default:"boot-time"`
+
+	// ElectionPriorities maps node hostnames or IPs to election priorities (highest wins)
+	// for the priority strategy, e.g. "cp-1:100,10.0.0.12:50"
+	ElectionPriorities map[string]int `envconfig:"TALOS_AUTO_BOOTSTRAP_ELECTION_PRIORITIES" yaml:"electionPriorities"`
+
+	// EligibleStages lists the machine stages a control plane node must be in to take
+	// part in elections (empty accepts all)
+	EligibleStages []string `envconfig:"TALOS_AUTO_BOOTSTRAP_ELIGIBLE_STAGES" yaml:"eligibleStages" default:"booting,running"`
+
+	// EligibleRequireReady requires control plane nodes to report their machine status as ready
+	// (off by default, as control plane nodes are not ready until etcd runs after bootstrap)
+	EligibleRequireReady bool `envconfig:"TALOS_AUTO_BOOTSTRAP_ELIGIBLE_REQUIRE_READY" yaml:"eligibleRequireReady" default:"false"`
+
+	// EligibleMinVersion is the minimum Talos version of eligible nodes (empty disables the check)
+	EligibleMinVersion string `envconfig:"TALOS_AUTO_BOOTSTRAP_ELIGIBLE_MIN_VERSION" yaml:"eligibleMinVersion"`
+
+	// EligibleMatchVersion requires eligible nodes to run the same Talos version as this node
+	EligibleMatchVersion bool `envconfig:"TALOS_AUTO_BOOTSTRAP_ELIGIBLE_MATCH_VERSION" yaml:"eligibleMatchVersion" default:"false"`
+
+	// EligibleEtcdStates lists the etcd service states of eligible nodes (empty accepts all)
+	EligibleEtcdStates []string `envconfig:"TALOS_AUTO_BOOTSTRAP_ELIGIBLE_ETCD_STATES" yaml:"eligibleEtcdStates" default:"Waiting,Preparing"`
+
+	// EligibleExcludeMaintenance rejects nodes in maintenance mode
+	EligibleExcludeMaintenance bool `envconfig:"TALOS_AUTO_BOOTSTRAP_ELIGIBLE_EXCLUDE_MAINTENANCE" yaml:"eligibleExcludeMaintenance" default:"true"`
+
+	// LeaderStallTimeout is how long followers wait for the same elected leader to
+	// bootstrap the cluster before demoting it and electing the next candidate.
+	// Zero disables failover
+	LeaderStallTimeout time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_LEADER_STALL_TIMEOUT" yaml:"leaderStallTimeout" default:"10m"`
+
+	// ViewCheck controls split-brain detection: before bootstrapping, the leader
+	// compares its candidate view with the views of all candidates. One of
+	// enforce (refuse to bootstrap until views converge), warn or off
+	ViewCheck string `envconfig:"TALOS_AUTO_BOOTSTRAP_VIEW_CHECK" yaml:"viewCheck" default:"enforce"`
+
+	// DryRun performs discovery, election, the pre-bootstrap delay and safety checks,
+	// but only records that the node would bootstrap instead of bootstrapping
+	DryRun bool `envconfig:"TALOS_AUTO_BOOTSTRAP_DRY_RUN" yaml:"dryRun" default:"false"`
+
+	// StatusDir is the directory where the local bootstrap state is persisted
+	StatusDir string `envconfig:"TALOS_AUTO_BOOTSTRAP_STATUS_DIR" yaml:"statusDir" default:"/run/autobootstrap/status"`
+
+	// TalosconfigExport enables exporting a talosconfig after a successful bootstrap
+	TalosconfigExport bool `envconfig:"TALOS_AUTO_BOOTSTRAP_TALOSCONFIG_EXPORT" yaml:"talosconfigExport" default:"false"`
+
+	// TalosconfigPath is the file the exported talosconfig is written to (empty disables)
+	TalosconfigPath string `envconfig:"TALOS_AUTO_BOOTSTRAP_TALOSCONFIG_PATH" yaml:"talosconfigPath" default:"/run/autobootstrap/talosconfig"`
+
+	// TalosconfigURL is an HTTP endpoint the exported talosconfig is POSTed to (empty disables)
+	TalosconfigURL string `envconfig:"TALOS_AUTO_BOOTSTRAP_TALOSCONFIG_URL" yaml:"talosconfigURL"`
+
+	// TalosconfigRole is the Talos API role granted by the exported talosconfig
+	TalosconfigRole string `envconfig:"TALOS_AUTO_BOOTSTRAP_TALOSCONFIG_ROLE" yaml:"talosconfigRole" default:"os:admin"`
+
+	// TalosconfigCertValidity is the validity period of the exported talosconfig
+	// certificate, counted from startup (when it is issued) rather than from the export
+	TalosconfigCertValidity time.Duration `envconfig:"TALOS_AUTO_BOOTSTRAP_TALOSCONFIG_CERT_VALIDITY" yaml:"talosconfigCertValidity" default:"24h"`
 }
 
-// Load reads configuration from environment variables.
+// Load reads configuration from the config file (if present) and environment
+// variables. Precedence is: defaults < config file < environment variables.
+// The config file path is read from TALOS_AUTO_BOOTSTRAP_CONFIG_FILE.
 func Load() (*Config, error) {
-	var cfg Config
-	err := envconfig.Process("", &cfg)
-	return &cfg, err
+	path, explicit := os.LookupEnv(ConfigFileEnv)
+	if !explicit {
+		path = DefaultConfigFile
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && !explicit:
+		data = nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	return load(data)
+}
+
+// load merges the config file contents (which may be empty) with defaults
+// and environment variables.
+func load(data []byte) (*Config, error) {
+	// Defaults and environment variables
+	var envCfg Config
+	if err := envconfig.Process("", &envCfg); err != nil {
+		return nil, err
+	}
+
+	cfg := envCfg
+	if len(data) == 0 {
+		return &cfg, nil
+	}
+
+	// The config file overrides defaults...
+	if err := decodeFile(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	// ...and explicitly set environment variables override the config file
+	envValues := reflect.ValueOf(envCfg)
+	cfgValues := reflect.ValueOf(&cfg).Elem()
+	for i := range envValues.NumField() {
+		name := envValues.Type().Field(i).Tag.Get("envconfig")
+		if _, ok := os.LookupEnv(name); ok {
+			cfgValues.Field(i).Set(envValues.Field(i))
+		}
+	}
+
+	return &cfg, nil
+}
+
+// file is the versioned config file schema.
+type file struct {
+	APIVersion string  `yaml:"apiVersion"`
+	Kind       string  `yaml:"kind"`
+	Config     *Config `yaml:",inline"`
+}
+
+// decodeFile decodes a YAML or JSON config file on top of cfg.
+// Unknown fields are rejected to catch typos.
+func decodeFile(data []byte, cfg *Config) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	doc := file{Config: cfg}
+	if err := decoder.Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			// Empty file (e.g. the placeholder shipped in the image)
+			return nil
+		}
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	if doc.APIVersion != APIVersion {
+		return fmt.Errorf("unsupported config file apiVersion %q, expected %q", doc.APIVersion, APIVersion)
+	}
+
+	if doc.Kind != Kind {
+		return fmt.Errorf("unsupported config file kind %q, expected %q", doc.Kind, Kind)
+	}
+
+	return nil
 }
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad_Defaults(t *testing.T) {
+	t.Setenv(ConfigFileEnv, filepath.Join(t.TempDir(), "empty.yaml"))
+	if err := os.WriteFile(os.Getenv(ConfigFileEnv), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.ScanInterval != 30*time.Second {
+		t.Errorf("expected default scan interval 30s, got %s", cfg.ScanInterval)
+	}
+	if cfg.QuorumNodes != 1 {
+		t.Errorf("expected default quorum nodes 1, got %d", cfg.QuorumNodes)
+	}
+}
+
+func TestLoad_FileAndEnvPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `apiVersion: autobootstrap.kommodity.io/v1alpha1
+kind: AutoBootstrapConfig
+scanInterval: 1m
+quorumNodes: 3
+scanConcurrency: 10
+`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(ConfigFileEnv, path)
+	t.Setenv("TALOS_AUTO_BOOTSTRAP_QUORUM_NODES", "5")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// From file
+	if cfg.ScanInterval != time.Minute {
+		t.Errorf("expected scan interval from file (1m), got %s", cfg.ScanInterval)
+	}
+	if cfg.ScanConcurrency != 10 {
+		t.Errorf("expected scan concurrency from file (10), got %d", cfg.ScanConcurrency)
+	}
+	// Environment overrides file
+	if cfg.QuorumNodes != 5 {
+		t.Errorf("expected quorum nodes from env (5), got %d", cfg.QuorumNodes)
+	}
+	// Default when set in neither
+	if cfg.ScanTimeout != 2*time.Second {
+		t.Errorf("expected default scan timeout (2s), got %s", cfg.ScanTimeout)
+	}
+}
+
+func TestLoad_JSONFile(t *testing.T) {
+	data := `{"apiVersion": "autobootstrap.kommodity.io/v1alpha1", "kind": "AutoBootstrapConfig",
+"preBootstrapDelay": "20s", "talosconfigExport": true}`
+
+	cfg, err := load([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.PreBootstrapDelay != 20*time.Second {
+		t.Errorf("expected pre-bootstrap delay 20s, got %s", cfg.PreBootstrapDelay)
+	}
+	if !cfg.TalosconfigExport {
+		t.Error("expected talosconfig export to be enabled")
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "unknown field",
+			data: "apiVersion: autobootstrap.kommodity.io/v1alpha1\nkind: AutoBootstrapConfig\nquorumNode: 3\n",
+		},
+		{
+			name: "wrong apiVersion",
+			data: "apiVersion: autobootstrap.kommodity.io/v1\nkind: AutoBootstrapConfig\n",
+		},
+		{
+			name: "wrong kind",
+			data: "apiVersion: autobootstrap.kommodity.io/v1alpha1\nkind: Other\n",
+		},
+		{
+			name: "invalid duration",
+			data: "apiVersion: autobootstrap.kommodity.io/v1alpha1\nkind: AutoBootstrapConfig\nscanInterval: soon\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := load([]byte(tt.data)); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestLoad_MissingExplicitFile(t *testing.T) {
+	t.Setenv(ConfigFileEnv, filepath.Join(t.TempDir(), "missing.yaml"))
+
+	if _, err := Load(); err == nil {
+		t.Error("expected error for missing explicitly configured file")
+	}
+}
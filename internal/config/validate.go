@@ -0,0 +1,306 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/blang/semver/v4"
+
+	"github.com/kommodity/talos-auto-bootstrap/pkg/bootstrap"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/election"
+)
+
+// talosRoles are the Talos API roles that can be granted to a client certificate.
+var talosRoles = []string{"os:admin", "os:operator", "os:reader", "os:etcd:backup"}
+
+// machineStages are the Talos machine stages accepted in eligibleStages.
+var machineStages = []string{"booting", "installing", "maintenance", "running", "rebooting",
+	"shutting down", "resetting", "upgrading"}
+
+// serviceStates are the Talos service states accepted in eligibleEtcdStates.
+var serviceStates = []string{"Initialized", "Preparing", "Waiting", "Running", "Stopping",
+	"Finished", "Failed", "Skipped", "Starting"}
+
+// Validate checks the configuration for invalid values and cross-field
+// inconsistencies. All problems are reported at once in the returned error.
+// Warnings describe valid but risky combinations.
+func (c *Config) Validate() (warnings []string, err error) {
+	var errs []error
+
+	if c.ScanInterval <= 0 {
+		errs = append(errs, fmt.Errorf("scanInterval must be positive, got %s", c.ScanInterval))
+	}
+
+	if c.FollowerCheckInterval <= 0 {
+		errs = append(errs, fmt.Errorf("followerCheckInterval must be positive, got %s", c.FollowerCheckInterval))
+	}
+
+	if c.QuorumNodes < 1 {
+		errs = append(errs, fmt.Errorf("quorumNodes must be at least 1, got %d", c.QuorumNodes))
+	}
+
+	if c.PreBootstrapDelay < 0 {
+		errs = append(errs, fmt.Errorf("preBootstrapDelay must not be negative, got %s", c.PreBootstrapDelay))
+	}
+
+	if c.MaxBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("maxBackoff must be positive, got %s", c.MaxBackoff))
+	}
+
+	if c.ScanTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("scanTimeout must be positive, got %s", c.ScanTimeout))
+	} else if c.ScanInterval > 0 && c.ScanTimeout > c.ScanInterval {
+		errs = append(errs, fmt.Errorf("scanTimeout (%s) must not exceed scanInterval (%s)",
+			c.ScanTimeout, c.ScanInterval))
+	}
+
+	errs = append(errs, c.validateScanRanges(&warnings)...)
+
+	if c.ScanConcurrency < 1 {
+		errs = append(errs, fmt.Errorf("scanConcurrency must be at least 1, got %d", c.ScanConcurrency))
+	}
+
+	if c.ScanAdaptiveTimeouts && c.ScanMinTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("scanMinTimeout must be positive, got %s", c.ScanMinTimeout))
+	}
+
+	if c.ScanAdaptiveTimeouts && !c.ScanPreProbe {
+		warnings = append(warnings, "scanAdaptiveTimeouts has no effect without scanPreProbe: "+
+			"only the pre-probe timeout adapts")
+	}
+
+	if c.ScanRate < 0 {
+		errs = append(errs, fmt.Errorf("scanRate must not be negative, got %d", c.ScanRate))
+	}
+
+	if c.ScanJitter < 0 {
+		errs = append(errs, fmt.Errorf("scanJitter must not be negative, got %s", c.ScanJitter))
+	}
+
+	if c.FullScanInterval < 0 {
+		errs = append(errs, fmt.Errorf("fullScanInterval must not be negative, got %s", c.FullScanInterval))
+	}
+
+	if c.ScanPreProbe {
+		if c.ScanPreProbeTimeout <= 0 {
+			errs = append(errs, fmt.Errorf("scanPreProbeTimeout must be positive, got %s", c.ScanPreProbeTimeout))
+		} else if c.ScanTimeout > 0 && c.ScanPreProbeTimeout > c.ScanTimeout {
+			errs = append(errs, fmt.Errorf("scanPreProbeTimeout (%s) must not exceed scanTimeout (%s)",
+				c.ScanPreProbeTimeout, c.ScanTimeout))
+		}
+
+		if c.ScanPreProbeConcurrency < 1 {
+			errs = append(errs, fmt.Errorf("scanPreProbeConcurrency must be at least 1, got %d",
+				c.ScanPreProbeConcurrency))
+		}
+	}
+
+	if c.RoleWaitTimeout < 0 {
+		errs = append(errs, fmt.Errorf("roleWaitTimeout must not be negative, got %s", c.RoleWaitTimeout))
+	}
+
+	switch {
+	case c.LeaderStallTimeout < 0:
+		errs = append(errs, fmt.Errorf("leaderStallTimeout must not be negative, got %s", c.LeaderStallTimeout))
+	case c.LeaderStallTimeout > 0 && c.LeaderStallTimeout <= c.PreBootstrapDelay:
+		errs = append(errs, fmt.Errorf("leaderStallTimeout (%s) must exceed preBootstrapDelay (%s)",
+			c.LeaderStallTimeout, c.PreBootstrapDelay))
+	case c.LeaderStallTimeout > 0 && c.LeaderStallTimeout < c.PreBootstrapDelay+bootstrap.EtcdReadyTimeout:
+		warnings = append(warnings, fmt.Sprintf("leaderStallTimeout (%s) is shorter than preBootstrapDelay plus "+
+			"the %s etcd readiness wait: a slow but healthy leader may be demoted",
+			c.LeaderStallTimeout, bootstrap.EtcdReadyTimeout))
+	}
+
+	if !slices.Contains(election.StrategyNames, c.ElectionStrategy) {
+		errs = append(errs, fmt.Errorf("electionStrategy must be one of %v, got %q",
+			election.StrategyNames, c.ElectionStrategy))
+	} else if len(c.ElectionPriorities) > 0 && c.ElectionStrategy != election.StrategyPriority {
+		warnings = append(warnings, fmt.Sprintf("electionPriorities are ignored with the %s election strategy",
+			c.ElectionStrategy))
+	}
+
+	errs = append(errs, c.validateInterfaceSelection()...)
+
+	errs = append(errs, c.validateEligibility()...)
+
+	if !slices.Contains(election.ViewCheckModes, c.ViewCheck) {
+		errs = append(errs, fmt.Errorf("viewCheck must be one of %v, got %q", election.ViewCheckModes, c.ViewCheck))
+	}
+
+	if c.TalosconfigExport {
+		errs = append(errs, c.validateTalosconfig(&warnings)...)
+	}
+
+	if c.ScanStopEarly && len(c.QuorumExpectedMembers) == 0 {
+		warnings = append(warnings, "scanStopEarly without quorumExpectedMembers: nodes may stop scanning "+
+			"after finding different peers and disagree on the leader")
+	}
+
+	if len(c.QuorumExpectedMembers) > 0 {
+		errs = append(errs, c.validateExpectedMembers(&warnings)...)
+	} else {
+		switch {
+		case c.QuorumNodes == 1:
+			warnings = append(warnings, "quorumNodes is 1: bootstrap proceeds as soon as this node is up, "+
+				"which is only safe for single control plane clusters")
+		case c.QuorumNodes > 1 && c.QuorumNodes%2 == 0:
+			warnings = append(warnings, fmt.Sprintf("quorumNodes is %d: an even number of etcd members "+
+				"tolerates no more failures than %d", c.QuorumNodes, c.QuorumNodes-1))
+		}
+	}
+
+	return warnings, errors.Join(errs...)
+}
+
+// validateExpectedMembers checks the expected-member quorum settings.
+func (c *Config) validateExpectedMembers(warnings *[]string) []error {
+	var errs []error
+
+	seen := make(map[string]bool, len(c.QuorumExpectedMembers))
+	for _, member := range c.QuorumExpectedMembers {
+		switch {
+		case member == "":
+			errs = append(errs, fmt.Errorf("quorumExpectedMembers must not contain empty entries"))
+		case seen[member]:
+			errs = append(errs, fmt.Errorf("quorumExpectedMembers contains %q more than once", member))
+		}
+		seen[member] = true
+	}
+
+	members := len(c.QuorumExpectedMembers)
+	switch {
+	case c.QuorumExpectedRequired < 0 || c.QuorumExpectedRequired > members:
+		errs = append(errs, fmt.Errorf("quorumExpectedRequired must be between 0 and %d, got %d",
+			members, c.QuorumExpectedRequired))
+	case c.QuorumExpectedRequired > 0 && c.QuorumExpectedRequired <= members/2:
+		*warnings = append(*warnings, fmt.Sprintf("quorumExpectedRequired is %d of %d expected members: "+
+			"less than a majority", c.QuorumExpectedRequired, members))
+	}
+
+	return errs
+}
+
+// validateScanRanges checks the scan ranges and the oversized range handling.
+func (c *Config) validateScanRanges(warnings *[]string) []error {
+	var errs []error
+
+	if c.ScanMaxHosts < 1 {
+		errs = append(errs, fmt.Errorf("scanMaxHosts must be at least 1, got %d", c.ScanMaxHosts))
+	}
+
+	if !slices.Contains(discovery.OversizedModes, c.ScanOversizedRanges) {
+		errs = append(errs, fmt.Errorf("scanOversizedRanges must be one of %v, got %q",
+			discovery.OversizedModes, c.ScanOversizedRanges))
+	}
+
+	for _, s := range c.ScanCIDRs {
+		r, err := discovery.ParseScanRange(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("scanCIDRs: %w", err))
+			continue
+		}
+
+		hosts := r.HostCount()
+		switch {
+		case c.ScanMaxHosts < 1 || hosts <= c.ScanMaxHosts:
+		case c.ScanOversizedRanges == discovery.OversizedSample:
+			*warnings = append(*warnings, fmt.Sprintf("scanCIDRs range %s has %d hosts: only %d are "+
+				"sampled each sweep, so peers may take several sweeps to be found", r.CIDR, hosts, c.ScanMaxHosts))
+		default:
+			errs = append(errs, fmt.Errorf("scanCIDRs range %s has %d hosts, more than scanMaxHosts (%d)",
+				r.CIDR, hosts, c.ScanMaxHosts))
+		}
+	}
+
+	return errs
+}
+
+// validateInterfaceSelection checks the interface selection policy.
+func (c *Config) validateInterfaceSelection() []error {
+	var errs []error
+
+	if !slices.Contains(discovery.InterfaceSelectModes, c.InterfaceSelection) {
+		errs = append(errs, fmt.Errorf("interfaceSelection must be one of %v, got %q",
+			discovery.InterfaceSelectModes, c.InterfaceSelection))
+	}
+
+	if _, err := discovery.NewInterfacePolicy(c.InterfaceSelection, c.InterfaceNames, c.InterfaceCIDRs,
+		c.InterfaceExclude, ""); err != nil {
+		errs = append(errs, fmt.Errorf("interface selection: %w", err))
+	}
+
+	switch {
+	case c.InterfaceSelection == discovery.InterfaceSelectName && len(c.InterfaceNames) == 0:
+		errs = append(errs, fmt.Errorf("interfaceSelection name requires interfaceNames"))
+	case c.InterfaceSelection == discovery.InterfaceSelectCIDR && len(c.InterfaceCIDRs) == 0:
+		errs = append(errs, fmt.Errorf("interfaceSelection cidr requires interfaceCIDRs"))
+	}
+
+	return errs
+}
+
+// validateEligibility checks the election eligibility filters.
+func (c *Config) validateEligibility() []error {
+	var errs []error
+
+	for _, stage := range c.EligibleStages {
+		if !slices.Contains(machineStages, stage) {
+			errs = append(errs, fmt.Errorf("eligibleStages must only contain %v, got %q", machineStages, stage))
+		}
+	}
+
+	for _, state := range c.EligibleEtcdStates {
+		if !slices.Contains(serviceStates, state) {
+			errs = append(errs, fmt.Errorf("eligibleEtcdStates must only contain %v, got %q", serviceStates, state))
+		}
+	}
+
+	if c.EligibleMinVersion != "" {
+		if err := validateVersion(c.EligibleMinVersion); err != nil {
+			errs = append(errs, fmt.Errorf("eligibleMinVersion is invalid: %w", err))
+		}
+	}
+
+	return errs
+}
+
+// validateTalosconfig checks the talosconfig export settings.
+func (c *Config) validateTalosconfig(warnings *[]string) []error {
+	var errs []error
+
+	if c.TalosconfigPath == "" && c.TalosconfigURL == "" {
+		errs = append(errs, fmt.Errorf("talosconfigExport requires talosconfigPath or talosconfigURL"))
+	}
+
+	if c.TalosconfigURL != "" {
+		u, err := url.Parse(c.TalosconfigURL)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("talosconfigURL is invalid: %w", err))
+		case u.Scheme != "http" && u.Scheme != "https":
+			errs = append(errs, fmt.Errorf("talosconfigURL must be an http or https URL, got %q", c.TalosconfigURL))
+		case u.Scheme == "http":
+			*warnings = append(*warnings, "talosconfigURL uses plain http: credentials are sent unencrypted")
+		}
+	}
+
+	if !slices.Contains(talosRoles, c.TalosconfigRole) {
+		errs = append(errs, fmt.Errorf("talosconfigRole must be one of %v, got %q", talosRoles, c.TalosconfigRole))
+	}
+
+	if c.TalosconfigCertValidity <= 0 {
+		errs = append(errs, fmt.Errorf("talosconfigCertValidity must be positive, got %s", c.TalosconfigCertValidity))
+	}
+
+	return errs
+}
+
+// validateVersion checks that version is a valid (optionally v-prefixed)
+// semantic version, as accepted by the eligibility filters.
+func validateVersion(version string) error {
+	_, err := semver.ParseTolerant(version)
+	return err
+}
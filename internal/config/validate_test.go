@@ -0,0 +1,264 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig returns a configuration that passes validation without warnings.
+func validConfig() *Config {
+	return &Config{
+		ScanInterval:            30 * time.Second,
+		FollowerCheckInterval:   15 * time.Second,
+		QuorumNodes:             3,
+		PreBootstrapDelay:       10 * time.Second,
+		MaxBackoff:              2 * time.Minute,
+		ScanTimeout:             2 * time.Second,
+		ScanConcurrency:         50,
+		ScanMaxHosts:            4096,
+		ScanOversizedRanges:     "refuse",
+		ScanPreProbe:            true,
+		FullScanInterval:        2 * time.Minute,
+		ScanAdaptiveTimeouts:    true,
+		ScanMinTimeout:          250 * time.Millisecond,
+		ScanJitter:              5 * time.Second,
+		ScanPreProbeTimeout:     500 * time.Millisecond,
+		ScanPreProbeConcurrency: 256,
+		RoleWaitTimeout:         2 * time.Minute,
+		LeaderStallTimeout:      10 * time.Minute,
+		ElectionStrategy:        "boot-time",
+		ViewCheck:               "enforce",
+		InterfaceSelection:      "first",
+		EligibleStages:          []string{"booting", "running"},
+		EligibleEtcdStates:      []string{"Waiting", "Preparing"},
+		TalosconfigPath:         "/run/autobootstrap/talosconfig",
+		TalosconfigRole:         "os:admin",
+		TalosconfigCertValidity: 24 * time.Hour,
+	}
+}
+
+func TestValidate_Valid(t *testing.T) {
+	warnings, err := validConfig().Validate()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+}
+
+func TestValidate_ReportsAllErrors(t *testing.T) {
+	cfg := validConfig()
+	cfg.QuorumNodes = 0
+	cfg.ScanConcurrency = -1
+	cfg.ScanTimeout = time.Minute
+	cfg.ElectionStrategy = "random"
+	cfg.LeaderStallTimeout = 5 * time.Second
+	cfg.ViewCheck = "always"
+	cfg.EligibleStages = []string{"Running"}
+	cfg.EligibleMinVersion = "latest"
+	cfg.InterfaceSelection = "name"
+	cfg.ScanCIDRs = []string{"10.1.0.0/16!10.2.0.0/24"}
+	cfg.ScanPreProbeConcurrency = 0
+	cfg.FullScanInterval = -time.Minute
+	cfg.ScanRate = -1
+
+	_, err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	for _, field := range []string{"quorumNodes", "scanConcurrency", "scanTimeout", "electionStrategy",
+		"leaderStallTimeout", "viewCheck", "eligibleStages", "eligibleMinVersion",
+		"interfaceNames", "scanCIDRs", "scanPreProbeConcurrency", "fullScanInterval", "scanRate"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected error to mention %s, got: %v", field, err)
+		}
+	}
+}
+
+func TestValidate_Talosconfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{
+			name:   "path only",
+			modify: func(c *Config) {},
+		},
+		{
+			name:    "no destination",
+			modify:  func(c *Config) { c.TalosconfigPath = "" },
+			wantErr: true,
+		},
+		{
+			name:    "invalid URL scheme",
+			modify:  func(c *Config) { c.TalosconfigURL = "ftp://example.com/talosconfig" },
+			wantErr: true,
+		},
+		{
+			name:    "unknown role",
+			modify:  func(c *Config) { c.TalosconfigRole = "os:root" },
+			wantErr: true,
+		},
+		{
+			name:    "zero validity",
+			modify:  func(c *Config) { c.TalosconfigCertValidity = 0 },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.TalosconfigExport = true
+			tt.modify(cfg)
+
+			_, err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidate_ExpectedMembers(t *testing.T) {
+	tests := []struct {
+		name     string
+		members  []string
+		required int
+		wantErr  bool
+	}{
+		{name: "all required", members: []string{"cp-1", "10.0.0.11", "cp-3"}},
+		{name: "majority required", members: []string{"cp-1", "cp-2", "cp-3"}, required: 2},
+		{name: "duplicate member", members: []string{"cp-1", "cp-1"}, wantErr: true},
+		{name: "empty member", members: []string{"cp-1", ""}, wantErr: true},
+		{name: "required exceeds members", members: []string{"cp-1"}, required: 2, wantErr: true},
+		{name: "negative required", members: []string{"cp-1"}, required: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.QuorumExpectedMembers = tt.members
+			cfg.QuorumExpectedRequired = tt.required
+
+			_, err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidate_ScanRanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		cidrs     []string
+		oversized string
+		wantErr   bool
+	}{
+		{name: "within limit", cidrs: []string{"10.1.0.0/20", "10.2.0.1/32"}, oversized: "refuse"},
+		{name: "oversized refused", cidrs: []string{"10.1.0.0/16"}, oversized: "refuse", wantErr: true},
+		{name: "oversized sampled", cidrs: []string{"10.0.0.0/8"}, oversized: "sample"},
+		{name: "with exclusions", cidrs: []string{"10.1.0.0/20!10.1.5.0/24!10.1.6.1"}, oversized: "refuse"},
+		{name: "exclusion outside of range", cidrs: []string{"10.1.0.0/20!10.2.0.0/24"}, oversized: "refuse",
+			wantErr: true},
+		{name: "IPv6 range", cidrs: []string{"fd00::/64"}, oversized: "refuse", wantErr: true},
+		{name: "invalid range", cidrs: []string{"10.1.0.0/33"}, oversized: "refuse", wantErr: true},
+		{name: "unknown mode", oversized: "truncate", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.ScanCIDRs = tt.cidrs
+			cfg.ScanOversizedRanges = tt.oversized
+
+			_, err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidate_Warnings(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{
+			name:   "single node quorum",
+			modify: func(c *Config) { c.QuorumNodes = 1 },
+			want:   "quorumNodes is 1",
+		},
+		{
+			name:   "even quorum",
+			modify: func(c *Config) { c.QuorumNodes = 4 },
+			want:   "quorumNodes is 4",
+		},
+		{
+			name:   "priorities without priority strategy",
+			modify: func(c *Config) { c.ElectionPriorities = map[string]int{"cp-1": 10} },
+			want:   "electionPriorities are ignored",
+		},
+		{
+			name:   "short leader stall timeout",
+			modify: func(c *Config) { c.LeaderStallTimeout = time.Minute },
+			want:   "slow but healthy leader",
+		},
+		{
+			name: "expected members below majority",
+			modify: func(c *Config) {
+				c.QuorumExpectedMembers = []string{"cp-1", "cp-2", "cp-3"}
+				c.QuorumExpectedRequired = 1
+			},
+			want: "less than a majority",
+		},
+		{
+			name: "sampled scan range",
+			modify: func(c *Config) {
+				c.ScanCIDRs = []string{"10.0.0.0/8"}
+				c.ScanOversizedRanges = "sample"
+			},
+			want: "sampled each sweep",
+		},
+		{
+			name:   "stop early without expected members",
+			modify: func(c *Config) { c.ScanStopEarly = true },
+			want:   "scanStopEarly without quorumExpectedMembers",
+		},
+		{
+			name:   "adaptive timeouts without pre-probe",
+			modify: func(c *Config) { c.ScanPreProbe = false },
+			want:   "scanAdaptiveTimeouts has no effect",
+		},
+		{
+			name: "plain http talosconfig URL",
+			modify: func(c *Config) {
+				c.TalosconfigExport = true
+				c.TalosconfigURL = "http://example.com/talosconfig"
+			},
+			want: "plain http",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+
+			warnings, err := cfg.Validate()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(warnings) != 1 || !strings.Contains(warnings[0], tt.want) {
+				t.Errorf("expected warning containing %q, got %v", tt.want, warnings)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package bootstrap
 
 import (
 	"context"
+	"fmt"
+	"net/netip"
 	"time"
 
 	talosclient "github.com/siderolabs/talos/pkg/machinery/client"
@@ -53,3 +55,33 @@ func WaitForEtcdReady(ctx context.Context, client *talosclient.Client,
 		}
 	}
 }
+
+// IsPeerBootstrapped checks if a peer node already runs etcd with members.
+// Unlike IsClusterBootstrapped, it returns an error if the peer's etcd state
+// cannot be read, e.g. because the peer is unreachable, as such a peer may
+// have bootstrapped etcd.
+func IsPeerBootstrapped(ctx context.Context, client *talosclient.Client, peer netip.Addr) (bool, error) {
+	ctx, cancel := context.WithTimeout(talosclient.WithNode(ctx, peer.String()), 5*time.Second)
+	defer cancel()
+
+	services, err := client.ServiceInfo(ctx, "etcd")
+	if err != nil {
+		return false, fmt.Errorf("failed to read etcd state of %s: %w", peer, err)
+	}
+	if len(services) == 0 || services[0].Service == nil {
+		return false, fmt.Errorf("etcd service of %s not found", peer)
+	}
+
+	// etcd waits in these states until the node bootstraps or joins a cluster
+	switch services[0].Service.State {
+	case "Initialized", "Waiting", "Preparing":
+		return false, nil
+	}
+
+	members, err := client.EtcdMemberList(ctx, &machineapi.EtcdMemberListRequest{})
+	if err != nil {
+		return false, fmt.Errorf("failed to list etcd members of %s: %w", peer, err)
+	}
+
+	return len(members.Messages) > 0 && len(members.Messages[0].Members) > 0, nil
+}
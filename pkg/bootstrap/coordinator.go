@@ -2,7 +2,9 @@ package bootstrap
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/netip"
 	"time"
 
 	machineapi "github.com/siderolabs/talos/pkg/machinery/api/machine"
@@ -10,24 +12,71 @@ import (
 	"go.uber.org/zap"
 )
 
+// EtcdReadyTimeout is how long the leader waits for etcd to become ready after bootstrap.
+const EtcdReadyTimeout = 5 * time.Minute
+
+// ErrFenced is returned by SafeBootstrap if the leader no longer holds the
+// current election epoch's token.
+var ErrFenced = errors.New("leader is fenced by a newer election epoch")
+
+// ErrAlreadyBootstrapped is returned by SafeBootstrap if the cluster was
+// bootstrapped by another node in the meantime, so the leader must neither
+// bootstrap nor report that it would have.
+var ErrAlreadyBootstrapped = errors.New("cluster was bootstrapped by another node")
+
+// FenceFunc verifies that the leader still holds the current election epoch's
+// token. It returns ErrFenced if a newer epoch exists.
+type FenceFunc func(ctx context.Context) error
+
+// PeerClientFunc returns the client used to query a peer, e.g. a pooled
+// connection to it.
+type PeerClientFunc func(peer netip.Addr) *talosclient.Client
+
 // Coordinator handles the safe execution of cluster bootstrap.
 type Coordinator struct {
 	client            *talosclient.Client
 	preBootstrapDelay time.Duration
+	dryRun            bool
+
+	// isBootstrapped and isPeerBootstrapped check the local node and a peer for etcd
+	isBootstrapped     func(ctx context.Context) (bool, error)
+	isPeerBootstrapped func(ctx context.Context, peer netip.Addr) (bool, error)
 }
 
 // NewCoordinator creates a new bootstrap coordinator.
-func NewCoordinator(client *talosclient.Client, preBootstrapDelay time.Duration) *Coordinator {
+// In dry-run mode all delays and safety checks are performed, but the
+// Bootstrap call is only logged. Peers are queried through the client
+// returned by peerClient, or through client's apid if peerClient is nil.
+func NewCoordinator(client *talosclient.Client, peerClient PeerClientFunc,
+	preBootstrapDelay time.Duration, dryRun bool) *Coordinator {
+
+	if peerClient == nil {
+		peerClient = func(netip.Addr) *talosclient.Client { return client }
+	}
+
 	return &Coordinator{
 		client:            client,
 		preBootstrapDelay: preBootstrapDelay,
+		dryRun:            dryRun,
+		isBootstrapped: func(ctx context.Context) (bool, error) {
+			return IsClusterBootstrapped(ctx, client)
+		},
+		isPeerBootstrapped: func(ctx context.Context, peer netip.Addr) (bool, error) {
+			return IsPeerBootstrapped(ctx, peerClient(peer), peer)
+		},
 	}
 }
 
 // SafeBootstrap executes the bootstrap process with safety checks.
 // It includes a pre-bootstrap delay to allow other nodes to catch up,
-// and performs a final check before executing bootstrap.
-func (c *Coordinator) SafeBootstrap(ctx context.Context) error {
+// and performs a final check before executing bootstrap. peers are other
+// control plane nodes (including demoted leaders) that must not run etcd yet.
+// fence is called right before the Bootstrap call and aborts it if the
+// leader was fenced. If the cluster or a peer was bootstrapped in the
+// meantime, ErrAlreadyBootstrapped is returned, also in dry-run mode. If the
+// etcd state of a peer cannot be read, SafeBootstrap fails without
+// bootstrapping, so the caller retries.
+func (c *Coordinator) SafeBootstrap(ctx context.Context, peers []netip.Addr, fence FenceFunc) error {
 	// Pre-bootstrap delay - allows other nodes time to participate in election
 	zap.L().Info("waiting before bootstrap", zap.Duration("delay", c.preBootstrapDelay))
 
@@ -38,9 +87,30 @@ func (c *Coordinator) SafeBootstrap(ctx context.Context) error {
 	}
 
 	// Final check - another node may have bootstrapped during our delay
-	bootstrapped, _ := IsClusterBootstrapped(ctx, c.client)
+	bootstrapped, _ := c.isBootstrapped(ctx)
 	if bootstrapped {
-		zap.L().Info("cluster was bootstrapped by another node")
+		return ErrAlreadyBootstrapped
+	}
+
+	// A previous (e.g. demoted) leader may have bootstrapped without us
+	// noticing. A peer whose etcd state is unknown may have, too.
+	for _, peer := range peers {
+		bootstrapped, err := c.isPeerBootstrapped(ctx, peer)
+		if err != nil {
+			return fmt.Errorf("refusing to bootstrap while the etcd state of peer %s is unknown: %w", peer, err)
+		}
+		if bootstrapped {
+			return fmt.Errorf("%w: peer %s already runs etcd", ErrAlreadyBootstrapped, peer)
+		}
+	}
+
+	// Another node may have claimed a newer election epoch during our delay
+	if err := fence(ctx); err != nil {
+		return err
+	}
+
+	if c.dryRun {
+		zap.L().Info("dry run: would bootstrap now, skipping bootstrap call")
 		return nil
 	}
 
@@ -55,5 +125,5 @@ func (c *Coordinator) SafeBootstrap(ctx context.Context) error {
 
 	// Wait for etcd to become ready
 	zap.L().Info("waiting for etcd to become ready")
-	return WaitForEtcdReady(ctx, c.client, 5*time.Minute)
+	return WaitForEtcdReady(ctx, c.client, EtcdReadyTimeout)
 }
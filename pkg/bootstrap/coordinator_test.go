@@ -0,0 +1,68 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestCoordinator_SafeBootstrap(t *testing.T) {
+	reachable := netip.MustParseAddr("10.0.0.2")
+	unreachable := netip.MustParseAddr("10.0.0.3")
+	bootstrapped := netip.MustParseAddr("10.0.0.4")
+
+	tests := []struct {
+		name              string
+		peers             []netip.Addr
+		localBootstrapped bool
+		wantErr           error
+		wantFailure       bool
+		wantFence         bool
+	}{
+		{name: "peers waiting", peers: []netip.Addr{reachable}, wantFence: true},
+		{name: "cluster bootstrapped", peers: []netip.Addr{reachable}, localBootstrapped: true,
+			wantErr: ErrAlreadyBootstrapped},
+		{name: "peer bootstrapped", peers: []netip.Addr{reachable, bootstrapped}, wantErr: ErrAlreadyBootstrapped},
+		{name: "peer unreachable", peers: []netip.Addr{reachable, unreachable}, wantFailure: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Coordinator{
+				dryRun: true,
+				isBootstrapped: func(context.Context) (bool, error) {
+					return tt.localBootstrapped, nil
+				},
+				isPeerBootstrapped: func(_ context.Context, peer netip.Addr) (bool, error) {
+					switch peer {
+					case unreachable:
+						return false, errors.New("connection refused")
+					case bootstrapped:
+						return true, nil
+					}
+					return false, nil
+				},
+			}
+
+			fenced := false
+			err := c.SafeBootstrap(t.Context(), tt.peers, func(context.Context) error {
+				fenced = true
+				return nil
+			})
+
+			switch {
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			case tt.wantFailure && err == nil:
+				t.Error("expected bootstrap to be refused")
+			case tt.wantErr == nil && !tt.wantFailure && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if fenced != tt.wantFence {
+				t.Errorf("expected fence check %v, got %v", tt.wantFence, fenced)
+			}
+		})
+	}
+}
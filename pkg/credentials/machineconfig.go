@@ -1,5 +1,14 @@
 package credentials
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
 const (
 	// StatePartitionPath is the path to the STATE partition block device.
 	StatePartitionPath = "/dev/disk/by-partlabel/STATE"
@@ -11,10 +20,155 @@ const (
 
 	// ConfigFileName is the name of the machine config file on the STATE partition.
 	ConfigFileName = "config.yaml"
+
+	// V1Alpha1Version is the version field of the legacy v1alpha1 machine config document.
+	V1Alpha1Version = "v1alpha1"
+)
+
+// Machine types as written in the v1alpha1 machine.type field.
+const (
+	MachineTypeInit         = "init"
+	MachineTypeControlPlane = "controlplane"
+	MachineTypeWorker       = "worker"
 )
 
 // MachineConfigCA contains the CA certificate and key from the machine config.
 type MachineConfigCA struct {
-	Crt string // Base64-encoded certificate
-	Key string // Base64-encoded private key
+	Crt string     // Base64-encoded certificate
+	Key *SecretKey // Base64-encoded private key, wiped after use
+}
+
+// MachineConfig contains the parts of the Talos machine config used by the extension.
+type MachineConfig struct {
+	// CA is the machine (Talos API) CA
+	CA MachineConfigCA
+	// MachineType is the value of machine.type (init, controlplane or worker)
+	MachineType string
+	// ClusterName is the value of cluster.clusterName
+	ClusterName string
+	// ClusterID is the value of cluster.id
+	ClusterID string
+	// ControlPlaneEndpoint is the value of cluster.controlPlane.endpoint
+	ControlPlaneEndpoint string
+	// NodeLabels is the value of machine.nodeLabels
+	NodeLabels map[string]string
+	// NodeAnnotations is the value of machine.nodeAnnotations
+	NodeAnnotations map[string]string
+	// Documents lists the kinds of all documents found in the config
+	Documents []string
+}
+
+// IsControlPlane reports whether the machine type denotes a control plane node.
+func (c *MachineConfig) IsControlPlane() bool {
+	return c.MachineType == MachineTypeControlPlane || c.MachineType == MachineTypeInit
+}
+
+// configDocument holds the header fields shared by all Talos config documents.
+type configDocument struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Version    string `yaml:"version"`
+}
+
+// v1alpha1Config represents the relevant parts of the v1alpha1 machine config document.
+type v1alpha1Config struct {
+	Machine struct {
+		Type            string            `yaml:"type"`
+		NodeLabels      map[string]string `yaml:"nodeLabels"`
+		NodeAnnotations map[string]string `yaml:"nodeAnnotations"`
+		CA              struct {
+			Crt string     `yaml:"crt"`
+			Key *SecretKey `yaml:"key"`
+		} `yaml:"ca"`
+	} `yaml:"machine"`
+	Cluster struct {
+		ID           string `yaml:"id"`
+		ClusterName  string `yaml:"clusterName"`
+		ControlPlane struct {
+			Endpoint string `yaml:"endpoint"`
+		} `yaml:"controlPlane"`
+	} `yaml:"cluster"`
+}
+
+// ParseMachineConfig parses a (possibly multi-document) Talos machine config.
+// It walks every YAML document, extracts the machine CA, machine type and
+// cluster identity from the v1alpha1 document, and records the kinds of all
+// other documents.
+func ParseMachineConfig(configData []byte) (*MachineConfig, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(configData))
+
+	var (
+		config   MachineConfig
+		v1alpha1 *v1alpha1Config
+	)
+
+	for i := 0; ; i++ {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse config document %d: %w", i, err)
+		}
+
+		if isEmptyDocument(&node) {
+			continue
+		}
+
+		var header configDocument
+		if err := node.Decode(&header); err != nil {
+			return nil, fmt.Errorf("failed to parse config document %d header: %w", i, err)
+		}
+
+		if header.Kind != "" {
+			config.Documents = append(config.Documents, header.Kind)
+			continue
+		}
+
+		if header.Version != V1Alpha1Version {
+			return nil, fmt.Errorf("config document %d has no kind and unsupported version %q", i, header.Version)
+		}
+
+		if v1alpha1 != nil {
+			return nil, fmt.Errorf("config contains more than one %s document", V1Alpha1Version)
+		}
+
+		v1alpha1 = &v1alpha1Config{}
+		if err := node.Decode(v1alpha1); err != nil {
+			return nil, fmt.Errorf("failed to parse %s document: %w", V1Alpha1Version, err)
+		}
+		config.Documents = append(config.Documents, V1Alpha1Version)
+	}
+
+	if v1alpha1 == nil {
+		return nil, fmt.Errorf("%s document not found in config", V1Alpha1Version)
+	}
+
+	if v1alpha1.Machine.CA.Crt == "" || v1alpha1.Machine.CA.Key.Empty() {
+		return nil, fmt.Errorf("machine.ca.crt or machine.ca.key not found in config")
+	}
+
+	config.CA = MachineConfigCA{
+		Crt: v1alpha1.Machine.CA.Crt,
+		Key: v1alpha1.Machine.CA.Key,
+	}
+	config.MachineType = v1alpha1.Machine.Type
+	config.ClusterName = v1alpha1.Cluster.ClusterName
+	config.ClusterID = v1alpha1.Cluster.ID
+	config.ControlPlaneEndpoint = v1alpha1.Cluster.ControlPlane.Endpoint
+	config.NodeLabels = v1alpha1.Machine.NodeLabels
+	config.NodeAnnotations = v1alpha1.Machine.NodeAnnotations
+
+	return &config, nil
+}
+
+// isEmptyDocument reports whether a YAML document has no content,
+// as produced by a leading or trailing "---" separator.
+func isEmptyDocument(node *yaml.Node) bool {
+	if len(node.Content) == 0 {
+		return true
+	}
+
+	content := node.Content[0]
+	return content.Kind == yaml.ScalarNode && content.Tag == "!!null"
 }
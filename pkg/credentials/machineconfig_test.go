@@ -0,0 +1,127 @@
+package credentials
+
+import (
+	"strings"
+	"testing"
+)
+
+const testV1Alpha1Config = `version: v1alpha1
+machine:
+  type: controlplane
+  nodeLabels:
+    autobootstrap.kommodity.io/priority: "10"
+  ca:
+    crt: Y3J0
+    key: a2V5
+cluster:
+  id: cluster-id
+  clusterName: test-cluster
+  controlPlane:
+    endpoint: https://10.0.0.100:6443
+`
+
+func TestParseMachineConfig_MultiDocument(t *testing.T) {
+	data := strings.Join([]string{
+		testV1Alpha1Config,
+		"apiVersion: v1alpha1\nkind: ExtensionServiceConfig\nname: kommodity-autobootstrap\n",
+		"apiVersion: v1alpha1\nkind: HostnameConfig\nhostname: cp-1\n",
+	}, "---\n")
+
+	config, err := ParseMachineConfig([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if config.CA.Crt != "Y3J0" || string(config.CA.Key.data) != "a2V5" {
+		t.Errorf("unexpected CA: %+v", config.CA)
+	}
+	if config.MachineType != MachineTypeControlPlane {
+		t.Errorf("expected machine type controlplane, got %q", config.MachineType)
+	}
+	if !config.IsControlPlane() {
+		t.Error("expected control plane machine config")
+	}
+	if config.ClusterName != "test-cluster" {
+		t.Errorf("expected cluster name test-cluster, got %q", config.ClusterName)
+	}
+	if config.ClusterID != "cluster-id" {
+		t.Errorf("expected cluster ID cluster-id, got %q", config.ClusterID)
+	}
+	if config.ControlPlaneEndpoint != "https://10.0.0.100:6443" {
+		t.Errorf("unexpected control plane endpoint %q", config.ControlPlaneEndpoint)
+	}
+	if config.NodeLabels["autobootstrap.kommodity.io/priority"] != "10" {
+		t.Errorf("unexpected node labels %v", config.NodeLabels)
+	}
+
+	expected := []string{"v1alpha1", "ExtensionServiceConfig", "HostnameConfig"}
+	if strings.Join(config.Documents, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected documents %v, got %v", expected, config.Documents)
+	}
+}
+
+func TestParseMachineConfig_V1Alpha1NotFirst(t *testing.T) {
+	data := "apiVersion: v1alpha1\nkind: ExtensionServiceConfig\nname: other\n---\n" +
+		testV1Alpha1Config + "---\n"
+
+	config, err := ParseMachineConfig([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.ClusterName != "test-cluster" {
+		t.Errorf("expected cluster name test-cluster, got %q", config.ClusterName)
+	}
+}
+
+func TestParseMachineConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "no v1alpha1 document",
+			data: "apiVersion: v1alpha1\nkind: ExtensionServiceConfig\nname: other\n",
+		},
+		{
+			name: "missing CA key",
+			data: "version: v1alpha1\nmachine:\n  type: worker\n  ca:\n    crt: Y3J0\n",
+		},
+		{
+			name: "duplicate v1alpha1 document",
+			data: testV1Alpha1Config + "---\n" + testV1Alpha1Config,
+		},
+		{
+			name: "invalid yaml",
+			data: "version: v1alpha1\nmachine: [\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseMachineConfig([]byte(tt.data)); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestMachineConfig_IsControlPlane(t *testing.T) {
+	tests := []struct {
+		machineType string
+		expected    bool
+	}{
+		{MachineTypeInit, true},
+		{MachineTypeControlPlane, true},
+		{MachineTypeWorker, false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.machineType, func(t *testing.T) {
+			config := &MachineConfig{MachineType: tt.machineType}
+			if config.IsControlPlane() != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, config.IsControlPlane())
+			}
+		})
+	}
+}
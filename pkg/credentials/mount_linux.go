@@ -8,45 +8,17 @@ import (
 	"path/filepath"
 
 	"golang.org/x/sys/unix"
-	"gopkg.in/yaml.v3"
 )
 
-// machineConfig represents the relevant parts of the Talos machine config.
-type machineConfig struct {
-	Machine struct {
-		CA struct {
-			Crt string `yaml:"crt"`
-			Key string `yaml:"key"`
-		} `yaml:"ca"`
-	} `yaml:"machine"`
-}
-
-// parseConfigForCA parses machine config YAML and extracts the CA.
-func parseConfigForCA(configData []byte) (*MachineConfigCA, error) {
-	var config machineConfig
-	if err := yaml.Unmarshal(configData, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
-	}
-
-	if config.Machine.CA.Crt == "" || config.Machine.CA.Key == "" {
-		return nil, fmt.Errorf("machine.ca.crt or machine.ca.key not found in config")
-	}
-
-	return &MachineConfigCA{
-		Crt: config.Machine.CA.Crt,
-		Key: config.Machine.CA.Key,
-	}, nil
-}
-
 const (
 	// MountBasePath is the base directory for temporary mount operations.
 	// Uses /run which is a writable tmpfs in Talos Linux.
 	MountBasePath = "/run/autobootstrap"
 )
 
-// ReadCAFromStatePartition reads the machine CA from the STATE partition.
-// It mounts the partition temporarily, reads the config, and extracts the CA.
-func ReadCAFromStatePartition() (*MachineConfigCA, error) {
+// ReadMachineConfigFromStatePartition reads the machine config from the STATE partition.
+// It mounts the partition temporarily, reads the config, and parses all of its documents.
+func ReadMachineConfigFromStatePartition() (*MachineConfig, error) {
 	// Ensure the base mount directory exists
 	if err := os.MkdirAll(MountBasePath, 0700); err != nil {
 		return nil, fmt.Errorf("failed to create mount base directory: %w", err)
@@ -76,8 +48,10 @@ func ReadCAFromStatePartition() (*MachineConfigCA, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
+	// The raw config contains the CA key, don't keep it around after parsing
+	defer clear(configData)
 
-	return parseConfigForCA(configData)
+	return ParseMachineConfig(configData)
 }
 
 // mountPartition mounts a partition at the specified mount point.
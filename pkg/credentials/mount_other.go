@@ -4,7 +4,7 @@ package credentials
 
 import "fmt"
 
-// ReadCAFromStatePartition is not supported on non-Linux platforms.
-func ReadCAFromStatePartition() (*MachineConfigCA, error) {
-	return nil, fmt.Errorf("ReadCAFromStatePartition is only supported on Linux")
+// ReadMachineConfigFromStatePartition is not supported on non-Linux platforms.
+func ReadMachineConfigFromStatePartition() (*MachineConfig, error) {
+	return nil, fmt.Errorf("ReadMachineConfigFromStatePartition is only supported on Linux")
 }
@@ -0,0 +1,96 @@
+package credentials
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"math/big"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted is printed in place of secret material.
+const redacted = "[REDACTED]"
+
+// SecretKey holds private key material (the base64-encoded PEM CA key).
+// The bytes are kept in a mutable buffer so they can be wiped once the key
+// has been used. It never prints or marshals its contents.
+//
+// Wiping is best effort for keys decoded from YAML: the parser holds the
+// document and the scalar value in its own buffers and in an immutable
+// string, which Wipe cannot reach. Those copies stay in memory until they
+// are garbage collected and overwritten.
+type SecretKey struct {
+	data []byte
+}
+
+// NewSecretKey creates a SecretKey from a copy of the given bytes.
+func NewSecretKey(data []byte) *SecretKey {
+	return &SecretKey{data: append([]byte(nil), data...)}
+}
+
+// Empty reports whether the key holds no data (or has been wiped).
+func (s *SecretKey) Empty() bool {
+	return s == nil || len(s.data) == 0
+}
+
+// Wipe zeroes the key material and releases the buffer.
+func (s *SecretKey) Wipe() {
+	if s == nil {
+		return
+	}
+	clear(s.data)
+	s.data = nil
+}
+
+// String implements fmt.Stringer without revealing the key.
+func (s *SecretKey) String() string {
+	return redacted
+}
+
+// GoString implements fmt.GoStringer without revealing the key.
+func (s *SecretKey) GoString() string {
+	return redacted
+}
+
+// MarshalText implements encoding.TextMarshaler without revealing the key,
+// so the key never ends up in JSON or structured log output.
+func (s *SecretKey) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// UnmarshalYAML copies the scalar value of a YAML node into the key buffer.
+// The node's string value is not wiped (see SecretKey).
+func (s *SecretKey) UnmarshalYAML(node *yaml.Node) error {
+	s.Wipe()
+	s.data = []byte(node.Value)
+	return nil
+}
+
+// wipePrivateKey zeroes the private components of a parsed private key.
+// This is best effort: copies made by the standard library are not reachable.
+func wipePrivateKey(key any) {
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		clear(k)
+	case *ecdsa.PrivateKey:
+		wipeBigInt(k.D)
+	case *rsa.PrivateKey:
+		wipeBigInt(k.D)
+		for _, p := range k.Primes {
+			wipeBigInt(p)
+		}
+		wipeBigInt(k.Precomputed.Dp)
+		wipeBigInt(k.Precomputed.Dq)
+		wipeBigInt(k.Precomputed.Qinv)
+	}
+}
+
+// wipeBigInt zeroes the words backing a big.Int.
+func wipeBigInt(n *big.Int) {
+	if n == nil {
+		return
+	}
+	clear(n.Bits())
+	n.SetInt64(0)
+}
@@ -24,7 +24,17 @@ const (
 
 // GenerateTLSConfig creates a TLS configuration with a client certificate
 // that has the os:admin role, using the provided CA certificate and key.
-func GenerateTLSConfig(caCertB64, caKeyB64 string) (*tls.Config, error) {
+// The decoded and parsed CA key only lives for the duration of this call and
+// is wiped before returning; the caller remains responsible for wiping caKey.
+func GenerateTLSConfig(caCertB64 string, caKey *SecretKey) (*tls.Config, error) {
+	return GenerateTLSConfigForRole(caCertB64, caKey, AdminRole, CertValidityDuration)
+}
+
+// GenerateTLSConfigForRole is like GenerateTLSConfig, but issues the client
+// certificate with the given Talos API role and validity period.
+func GenerateTLSConfigForRole(caCertB64 string, caKey *SecretKey, role string,
+	validity time.Duration) (*tls.Config, error) {
+
 	// Parse CA certificate
 	caCert, err := parseCACertificate(caCertB64)
 	if err != nil {
@@ -32,10 +42,11 @@ func GenerateTLSConfig(caCertB64, caKeyB64 string) (*tls.Config, error) {
 	}
 
 	// Parse CA private key
-	caKey, err := parseCAPrivateKey(caKeyB64)
+	caPrivKey, err := parseCAPrivateKey(caKey)
 	if err != nil {
 		return nil, fmt.Errorf("CA private key: %w", err)
 	}
+	defer wipePrivateKey(caPrivKey)
 
 	// Generate a new key pair for the client certificate
 	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
@@ -52,18 +63,18 @@ func GenerateTLSConfig(caCertB64, caKeyB64 string) (*tls.Config, error) {
 	clientCertTemplate := &x509.Certificate{
 		SerialNumber: serialNumber,
 		Subject: pkix.Name{
-			Organization: []string{AdminRole}, // This grants os:admin role
+			Organization: []string{role}, // Organization is the Talos API role
 			CommonName:   "autobootstrap-extension",
 		},
 		NotBefore:             time.Now().Add(-1 * time.Hour),
-		NotAfter:              time.Now().Add(CertValidityDuration),
+		NotAfter:              time.Now().Add(validity),
 		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
 		BasicConstraintsValid: true,
 	}
 
 	// Sign the client certificate with the CA
-	clientCertDER, err := x509.CreateCertificate(rand.Reader, clientCertTemplate, caCert, clientPub, caKey)
+	clientCertDER, err := x509.CreateCertificate(rand.Reader, clientCertTemplate, caCert, clientPub, caPrivKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create client certificate: %w", err)
 	}
@@ -74,6 +85,7 @@ func GenerateTLSConfig(caCertB64, caKeyB64 string) (*tls.Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal client key: %w", err)
 	}
+	defer clear(clientKeyPEM)
 
 	clientTLSCert, err := tls.X509KeyPair(clientCertPEM, clientKeyPEM)
 	if err != nil {
@@ -124,24 +136,29 @@ func parseCACertificate(caCertB64 string) (*x509.Certificate, error) {
 }
 
 // parseCAPrivateKey decodes and parses a base64-encoded PEM CA private key.
-func parseCAPrivateKey(caKeyB64 string) (any, error) {
-	if caKeyB64 == "" {
+// Intermediate buffers holding the decoded key are wiped before returning.
+func parseCAPrivateKey(caKey *SecretKey) (any, error) {
+	if caKey.Empty() {
 		return nil, fmt.Errorf("key data is empty")
 	}
 
-	caKeyPEM, err := base64.StdEncoding.DecodeString(caKeyB64)
+	caKeyPEM := make([]byte, base64.StdEncoding.DecodedLen(len(caKey.data)))
+	defer clear(caKeyPEM)
+
+	n, err := base64.StdEncoding.Decode(caKeyPEM, caKey.data)
 	if err != nil {
 		return nil, fmt.Errorf("base64 decode failed: %w", err)
 	}
 
-	if len(caKeyPEM) == 0 {
+	if n == 0 {
 		return nil, fmt.Errorf("decoded key data is empty")
 	}
 
-	block, _ := pem.Decode(caKeyPEM)
+	block, _ := pem.Decode(caKeyPEM[:n])
 	if block == nil {
 		return nil, fmt.Errorf("PEM decode failed: no valid PEM block found")
 	}
+	defer clear(block.Bytes)
 
 	key, err := parsePrivateKey(block)
 	if err != nil {
@@ -0,0 +1,107 @@
+package credentials
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCA generates a self-signed ED25519 CA and returns it in the
+// base64-encoded PEM form used by the machine config.
+func newTestCA(t *testing.T) (string, *SecretKey) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"talos"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("failed to marshal CA key: %v", err)
+	}
+
+	crtPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "ED25519 PRIVATE KEY", Bytes: keyDER})
+
+	return base64.StdEncoding.EncodeToString(crtPEM),
+		NewSecretKey([]byte(base64.StdEncoding.EncodeToString(keyPEM)))
+}
+
+func TestGenerateTLSConfig(t *testing.T) {
+	crt, key := newTestCA(t)
+
+	tlsConfig, err := GenerateTLSConfig(crt, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(tlsConfig.Certificates) != 1 {
+		t.Fatalf("expected 1 client certificate, got %d", len(tlsConfig.Certificates))
+	}
+
+	leaf, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatalf("failed to parse client certificate: %v", err)
+	}
+	if len(leaf.Subject.Organization) != 1 || leaf.Subject.Organization[0] != AdminRole {
+		t.Errorf("expected organization %q, got %v", AdminRole, leaf.Subject.Organization)
+	}
+
+	// The caller's key is left untouched, so it can still be wiped explicitly
+	if key.Empty() {
+		t.Error("expected caller key to be left intact")
+	}
+	key.Wipe()
+	if !key.Empty() {
+		t.Error("expected key to be empty after wipe")
+	}
+
+	if _, err := GenerateTLSConfig(crt, key); err == nil {
+		t.Error("expected error when using a wiped key")
+	}
+}
+
+func TestSecretKey_Redacted(t *testing.T) {
+	key := NewSecretKey([]byte("c2VjcmV0"))
+
+	ca := MachineConfigCA{Crt: "Y3J0", Key: key}
+	for _, out := range []string{
+		fmt.Sprintf("%v", ca),
+		fmt.Sprintf("%+v", ca),
+		fmt.Sprintf("%#v", ca),
+		key.String(),
+	} {
+		if strings.Contains(out, "c2VjcmV0") {
+			t.Errorf("key material leaked in %q", out)
+		}
+	}
+
+	text, err := key.MarshalText()
+	if err != nil || string(text) != redacted {
+		t.Errorf("expected redacted text, got %q (%v)", text, err)
+	}
+}
@@ -0,0 +1,95 @@
+package discovery
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// AdaptiveTimeouts derives probe timeouts from the round-trip times observed
+// to responding hosts, like TCP's retransmission timeout (RFC 6298): the
+// timeout is the smoothed RTT plus four times its variation. Hosts without
+// samples of their own use the estimate over all hosts, so unresponsive
+// addresses are given up on quickly once responders have been measured.
+type AdaptiveTimeouts struct {
+	mu sync.Mutex
+
+	// floor is the lower bound of derived timeouts
+	floor  time.Duration
+	global rttEstimate
+	hosts  map[netip.Addr]*rttEstimate
+}
+
+// rttEstimate is a smoothed round-trip time and its variation.
+type rttEstimate struct {
+	srtt   time.Duration
+	rttvar time.Duration
+	valid  bool
+}
+
+// observe folds a round-trip time sample into the estimate.
+func (e *rttEstimate) observe(rtt time.Duration) {
+	if !e.valid {
+		e.srtt, e.rttvar, e.valid = rtt, rtt/2, true
+		return
+	}
+
+	diff := e.srtt - rtt
+	if diff < 0 {
+		diff = -diff
+	}
+	e.rttvar = (3*e.rttvar + diff) / 4
+	e.srtt = (7*e.srtt + rtt) / 8
+}
+
+// timeout returns the timeout derived from the estimate.
+func (e *rttEstimate) timeout() time.Duration {
+	return e.srtt + 4*e.rttvar
+}
+
+// NewAdaptiveTimeouts creates adaptive timeouts that never drop below floor.
+func NewAdaptiveTimeouts(floor time.Duration) *AdaptiveTimeouts {
+	return &AdaptiveTimeouts{floor: floor, hosts: make(map[netip.Addr]*rttEstimate)}
+}
+
+// Observe records the round-trip time of a successful exchange with ip.
+// Observing on a nil AdaptiveTimeouts does nothing.
+func (a *AdaptiveTimeouts) Observe(ip netip.Addr, rtt time.Duration) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	host, ok := a.hosts[ip]
+	if !ok {
+		host = &rttEstimate{}
+		a.hosts[ip] = host
+	}
+
+	host.observe(rtt)
+	a.global.observe(rtt)
+}
+
+// Timeout returns the timeout for ip, between the minimum and limit. Without
+// any samples, limit is returned. A nil AdaptiveTimeouts always returns limit.
+func (a *AdaptiveTimeouts) Timeout(ip netip.Addr, limit time.Duration) time.Duration {
+	if a == nil {
+		return limit
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	estimate := &a.global
+	if host, ok := a.hosts[ip]; ok {
+		estimate = host
+	}
+
+	if !estimate.valid {
+		return limit
+	}
+
+	return min(max(estimate.timeout(), a.floor), limit)
+}
@@ -0,0 +1,43 @@
+package discovery
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveTimeouts(t *testing.T) {
+	near := netip.MustParseAddr("10.1.0.2")
+	far := netip.MustParseAddr("10.9.0.2")
+	unknown := netip.MustParseAddr("10.1.0.77")
+	limit := 2 * time.Second
+
+	timeouts := NewAdaptiveTimeouts(100 * time.Millisecond)
+	if got := timeouts.Timeout(unknown, limit); got != limit {
+		t.Errorf("expected the limit without samples, got %s", got)
+	}
+
+	for range 10 {
+		timeouts.Observe(near, 2*time.Millisecond)
+	}
+	timeouts.Observe(far, 400*time.Millisecond)
+
+	if got := timeouts.Timeout(near, limit); got != 100*time.Millisecond {
+		t.Errorf("expected the minimum for a fast host, got %s", got)
+	}
+	if got := timeouts.Timeout(far, limit); got != 1200*time.Millisecond {
+		t.Errorf("expected 1.2s for a slow host, got %s", got)
+	}
+	if got := timeouts.Timeout(far, time.Second); got != time.Second {
+		t.Errorf("expected the limit to cap the timeout, got %s", got)
+	}
+	if got := timeouts.Timeout(unknown, limit); got >= limit {
+		t.Errorf("expected the global estimate for an unknown host, got %s", got)
+	}
+
+	var disabled *AdaptiveTimeouts
+	disabled.Observe(near, time.Millisecond)
+	if got := disabled.Timeout(near, limit); got != limit {
+		t.Errorf("expected the limit when disabled, got %s", got)
+	}
+}
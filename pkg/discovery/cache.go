@@ -0,0 +1,130 @@
+package discovery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kommodity/talos-auto-bootstrap/internal/fsutil"
+)
+
+// PeerCache remembers the Talos nodes found by previous scans, so that known
+// peers can be re-probed every interval while full sweeps of the scan ranges
+// run less frequently. It is persisted to survive extension restarts.
+type PeerCache struct {
+	mu sync.Mutex
+
+	path  string
+	state peerCacheState
+	now   func() time.Time
+}
+
+// peerCacheState is the persisted peer cache.
+type peerCacheState struct {
+	// LastSweep is when the scan ranges were last swept completely
+	LastSweep time.Time `json:"lastSweep"`
+	// Peers are the Talos nodes found by the last scan
+	Peers []DiscoveredNode `json:"peers"`
+}
+
+// NewPeerCache creates a peer cache persisted to path (empty disables
+// persistence). A previously persisted cache is loaded; if it cannot be
+// read, the cache starts empty and the error is returned.
+func NewPeerCache(path string) (*PeerCache, error) {
+	c := &PeerCache{path: path, now: time.Now}
+	if path == "" {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return c, nil
+	case err != nil:
+		return c, fmt.Errorf("failed to read peer cache: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &c.state); err != nil {
+		c.state = peerCacheState{}
+		return c, fmt.Errorf("failed to parse peer cache: %w", err)
+	}
+
+	return c, nil
+}
+
+// SweepDue reports whether a full sweep of the scan ranges is due: the cache
+// is empty, or the last full sweep is at least interval ago.
+func (c *PeerCache) SweepDue(interval time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.state.Peers) == 0 || c.now().Sub(c.state.LastSweep) >= interval
+}
+
+// Known returns the addresses of the cached peers within ranges.
+func (c *PeerCache) Known(ranges []ScanRange) []netip.Addr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var known []netip.Addr
+	for _, peer := range c.state.Peers {
+		if slices.ContainsFunc(ranges, func(r ScanRange) bool { return r.Contains(peer.IP) }) {
+			known = append(known, peer.IP)
+		}
+	}
+
+	return known
+}
+
+// Contains reports whether ip is a cached peer, i.e. it was found by the last
+// full sweep or a later scan.
+func (c *PeerCache) Contains(ip netip.Addr) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.ContainsFunc(c.state.Peers, func(peer DiscoveredNode) bool { return peer.IP == ip })
+}
+
+// Update records the result of a scan and persists the cache. full marks the
+// scan as a complete sweep of the scan ranges, which replaces the cached
+// peers. An incremental scan only updates the peers it found: a known peer
+// that did not answer, e.g. while rebooting, stays cached until a full sweep
+// confirms it is gone.
+func (c *PeerCache) Update(peers []DiscoveredNode, full bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if full {
+		c.state.Peers = slices.Clone(peers)
+		c.state.LastSweep = c.now()
+	} else {
+		for _, peer := range peers {
+			i := slices.IndexFunc(c.state.Peers, func(cached DiscoveredNode) bool { return cached.IP == peer.IP })
+			if i < 0 {
+				c.state.Peers = append(c.state.Peers, peer)
+			} else {
+				c.state.Peers[i] = peer
+			}
+		}
+	}
+
+	if c.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(&c.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal peer cache: %w", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(c.path, data); err != nil {
+		return fmt.Errorf("failed to write peer cache: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package discovery
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPeerCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cache, err := NewPeerCache(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cache.now = func() time.Time { return now }
+
+	if !cache.SweepDue(time.Minute) {
+		t.Error("expected a sweep to be due for an empty cache")
+	}
+
+	peers := []DiscoveredNode{
+		{IP: netip.MustParseAddr("10.1.0.2"), Hostname: "cp-2"},
+		{IP: netip.MustParseAddr("10.9.0.3"), Hostname: "cp-3"},
+	}
+	if err := cache.Update(peers, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now = now.Add(30 * time.Second)
+	if cache.SweepDue(time.Minute) {
+		t.Error("expected no sweep to be due right after a sweep")
+	}
+
+	// A partial scan does not reset the sweep timer
+	if err := cache.Update(peers, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now = now.Add(30 * time.Second)
+	if !cache.SweepDue(time.Minute) {
+		t.Error("expected a sweep to be due after the interval")
+	}
+
+	// The cache survives restarts and only returns peers within the ranges
+	restored, err := NewPeerCache(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	known := restored.Known([]ScanRange{{CIDR: netip.MustParsePrefix("10.1.0.0/24")}})
+	if !slices.Equal(known, []netip.Addr{netip.MustParseAddr("10.1.0.2")}) {
+		t.Errorf("expected only 10.1.0.2 to be known, got %v", known)
+	}
+}
+
+func TestPeerCache_IncrementalScanKeepsMissingPeers(t *testing.T) {
+	cache, err := NewPeerCache("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cp2 := DiscoveredNode{IP: netip.MustParseAddr("10.1.0.2"), Hostname: "cp-2"}
+	cp3 := DiscoveredNode{IP: netip.MustParseAddr("10.1.0.3"), Hostname: "cp-3"}
+	cp4 := DiscoveredNode{IP: netip.MustParseAddr("10.1.0.4"), Hostname: "cp-4"}
+	ranges := []ScanRange{{CIDR: netip.MustParsePrefix("10.1.0.0/24")}}
+
+	if err := cache.Update([]DiscoveredNode{cp2, cp3}, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// cp-3 does not answer an incremental scan, cp-4 is new
+	if err := cache.Update([]DiscoveredNode{cp2, cp4}, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []netip.Addr{cp2.IP, cp3.IP, cp4.IP}
+	if known := cache.Known(ranges); !slices.Equal(known, want) {
+		t.Errorf("expected %v to be known after an incremental scan, got %v", want, known)
+	}
+
+	// A full sweep confirms cp-3 is gone
+	if err := cache.Update([]DiscoveredNode{cp2, cp4}, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = []netip.Addr{cp2.IP, cp4.IP}
+	if known := cache.Known(ranges); !slices.Equal(known, want) {
+		t.Errorf("expected %v to be known after a full sweep, got %v", want, known)
+	}
+	if cache.Contains(cp3.IP) || !cache.Contains(cp4.IP) {
+		t.Errorf("expected only the peers of the last sweep to be contained")
+	}
+}
+
+func TestPeerCache_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := NewPeerCache(path)
+	if err == nil {
+		t.Error("expected error for a corrupt cache")
+	}
+	if !cache.SweepDue(time.Hour) {
+		t.Error("expected a corrupt cache to start empty")
+	}
+}
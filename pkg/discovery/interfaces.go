@@ -0,0 +1,291 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Interface selection modes.
+const (
+	// InterfaceSelectFirst selects the first up, non-loopback interface with an IPv4 address
+	InterfaceSelectFirst = "first"
+	// InterfaceSelectName selects the first interface whose name matches InterfacePolicy.Names
+	InterfaceSelectName = "name"
+	// InterfaceSelectCIDR selects the first address within InterfacePolicy.CIDRs
+	InterfaceSelectCIDR = "cidr"
+	// InterfaceSelectDefaultRoute selects the interface holding the default route
+	InterfaceSelectDefaultRoute = "default-route"
+	// InterfaceSelectEndpointRoute selects the interface used to reach InterfacePolicy.Endpoint
+	InterfaceSelectEndpointRoute = "endpoint-route"
+)
+
+// InterfaceSelectModes lists all interface selection modes.
+var InterfaceSelectModes = []string{InterfaceSelectFirst, InterfaceSelectName, InterfaceSelectCIDR,
+	InterfaceSelectDefaultRoute, InterfaceSelectEndpointRoute}
+
+// InterfacePolicy decides which interface discovery uses on multi-homed nodes.
+// The zero value selects the first suitable interface.
+type InterfacePolicy struct {
+	// Mode is one of InterfaceSelectModes (empty means first)
+	Mode string
+	// Names are regular expressions matched against the full interface name
+	Names []*regexp.Regexp
+	// CIDRs are the networks the selected address must belong to
+	CIDRs []netip.Prefix
+	// Endpoint is the control plane endpoint (URL or host[:port]) for endpoint-route
+	Endpoint string
+	// Exclude are interface name glob patterns that are never selected, e.g. cni*
+	Exclude []string
+}
+
+// NewInterfacePolicy returns an interface selection policy. Names are
+// regular expressions matched against the full interface name, cidrs are
+// parsed as networks and exclude are glob patterns. All invalid values are
+// reported at once in the returned error.
+func NewInterfacePolicy(mode string, names, cidrs, exclude []string, endpoint string) (InterfacePolicy, error) {
+	policy := InterfacePolicy{Mode: mode, Endpoint: endpoint, Exclude: exclude}
+
+	var errs []error
+	for _, name := range names {
+		re, err := regexp.Compile("^(?:" + name + ")$")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid interface name pattern %q: %w", name, err))
+			continue
+		}
+		policy.Names = append(policy.Names, re)
+	}
+
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid interface CIDR %q: %w", cidr, err))
+			continue
+		}
+		policy.CIDRs = append(policy.CIDRs, prefix.Masked())
+	}
+
+	for _, pattern := range exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid interface exclude pattern %q: %w", pattern, err))
+		}
+	}
+
+	return policy, errors.Join(errs...)
+}
+
+// interfaceAddr is an IPv4 address assigned to an interface.
+type interfaceAddr struct {
+	name   string
+	prefix netip.Prefix
+}
+
+// GetNetworkInfo retrieves network configuration using Go's net package,
+// selecting the first suitable interface. No interfaces are excluded; the
+// configured exclusions are applied through GetNetworkInfoWithPolicy.
+// This is used instead of COSI when COSI access is not available.
+func GetNetworkInfo() (*NetworkInfo, error) {
+	return GetNetworkInfoWithPolicy(InterfacePolicy{})
+}
+
+// GetNetworkInfoWithPolicy retrieves network configuration using Go's net
+// package, selecting the interface according to policy.
+func GetNetworkInfoWithPolicy(policy InterfacePolicy) (*NetworkInfo, error) {
+	addrs, err := listInterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	routeIface, gateway, routeErr := getDefaultRoute()
+
+	var target netip.Addr
+	switch policy.Mode {
+	case InterfaceSelectDefaultRoute:
+		if routeErr != nil {
+			return nil, fmt.Errorf("failed to find the default route: %w", routeErr)
+		}
+	case InterfaceSelectEndpointRoute:
+		target, err = routeSource(policy.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	selected, err := selectInterface(policy, addrs, routeIface, target)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &NetworkInfo{
+		LocalIP:  selected.prefix.Addr(),
+		CIDR:     selected.prefix.Masked(),
+		LinkName: selected.name,
+	}
+
+	if routeErr == nil {
+		info.Gateway = gateway
+	}
+
+	for _, addr := range addrs {
+		info.LocalAddrs = append(info.LocalAddrs, addr.prefix.Addr())
+		if !excluded(addr.name, policy.Exclude) && !slices.Contains(info.ConnectedSubnets, addr.prefix.Masked()) {
+			info.ConnectedSubnets = append(info.ConnectedSubnets, addr.prefix.Masked())
+		}
+	}
+
+	return info, nil
+}
+
+// listInterfaceAddrs lists the IPv4 addresses of all up, non-loopback
+// interfaces, skipping loopback and link-local addresses.
+func listInterfaceAddrs() ([]interfaceAddr, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+
+	var out []interfaceAddr
+
+	for _, iface := range interfaces {
+		// Skip loopback and down interfaces
+		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+
+			ip := ipNet.IP.To4()
+			if ip == nil {
+				continue // Skip IPv6
+			}
+
+			// Skip loopback and link-local
+			if ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+				continue
+			}
+
+			netipAddr, ok := netip.AddrFromSlice(ip)
+			if !ok {
+				continue
+			}
+
+			ones, _ := ipNet.Mask.Size()
+			out = append(out, interfaceAddr{name: iface.Name, prefix: netip.PrefixFrom(netipAddr, ones)})
+		}
+	}
+
+	return out, nil
+}
+
+// selectInterface picks an address according to policy. routeIface is the
+// interface holding the default route and target the local address used to
+// reach the control plane endpoint; each is only used by its mode.
+func selectInterface(policy InterfacePolicy, addrs []interfaceAddr, routeIface string,
+	target netip.Addr) (interfaceAddr, error) {
+
+	for _, addr := range addrs {
+		if excluded(addr.name, policy.Exclude) {
+			continue
+		}
+
+		var match bool
+		switch policy.Mode {
+		case InterfaceSelectFirst, "":
+			match = true
+		case InterfaceSelectName:
+			match = slices.ContainsFunc(policy.Names, func(re *regexp.Regexp) bool {
+				return re.MatchString(addr.name)
+			})
+		case InterfaceSelectCIDR:
+			match = slices.ContainsFunc(policy.CIDRs, func(cidr netip.Prefix) bool {
+				return cidr.Contains(addr.prefix.Addr())
+			})
+		case InterfaceSelectDefaultRoute:
+			match = addr.name == routeIface
+		case InterfaceSelectEndpointRoute:
+			match = addr.prefix.Addr() == target
+		default:
+			return interfaceAddr{}, fmt.Errorf("unknown interface selection mode %q", policy.Mode)
+		}
+
+		if match {
+			return addr, nil
+		}
+	}
+
+	return interfaceAddr{}, fmt.Errorf("no suitable network address found (interface selection %q)",
+		policy.Mode)
+}
+
+// excluded reports whether an interface name matches any exclude pattern.
+func excluded(name string, patterns []string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		ok, err := path.Match(pattern, name)
+		return err == nil && ok
+	})
+}
+
+// routeSource returns the local address the kernel uses to reach the control
+// plane endpoint. Connecting a UDP socket selects a route without sending packets.
+func routeSource(endpoint string) (netip.Addr, error) {
+	host, err := endpointHost(endpoint)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	conn, err := net.DialTimeout("udp4", net.JoinHostPort(host, "6443"), 5*time.Second)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("failed to find the route to %s: %w", host, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	local, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return netip.Addr{}, fmt.Errorf("failed to find the route to %s", host)
+	}
+
+	addr, ok := netip.AddrFromSlice(local.IP.To4())
+	if !ok {
+		return netip.Addr{}, fmt.Errorf("failed to find an IPv4 route to %s", host)
+	}
+
+	return addr, nil
+}
+
+// endpointHost extracts the host from a control plane endpoint, which is
+// either a URL (https://host:6443) or host[:port].
+func endpointHost(endpoint string) (string, error) {
+	if endpoint == "" {
+		return "", fmt.Errorf("no control plane endpoint to route to")
+	}
+
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return "", fmt.Errorf("invalid control plane endpoint: %w", err)
+		}
+		return u.Hostname(), nil
+	}
+
+	if host, _, err := net.SplitHostPort(endpoint); err == nil {
+		return host, nil
+	}
+
+	return endpoint, nil
+}
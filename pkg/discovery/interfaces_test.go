@@ -0,0 +1,141 @@
+package discovery
+
+import (
+	"net/netip"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSelectInterface(t *testing.T) {
+	addrs := []interfaceAddr{
+		{name: "cni0", prefix: netip.MustParsePrefix("10.244.0.1/24")},
+		{name: "eth0", prefix: netip.MustParsePrefix("10.10.0.5/24")}, // IPMI VLAN
+		{name: "bond0", prefix: netip.MustParsePrefix("192.168.10.5/24")},
+		{name: "bond0.20", prefix: netip.MustParsePrefix("172.20.0.5/16")},
+	}
+
+	tests := []struct {
+		name       string
+		policy     InterfacePolicy
+		routeIface string
+		target     string
+		expected   string
+		wantErr    bool
+	}{
+		{
+			name:     "first without exclusions",
+			policy:   InterfacePolicy{},
+			expected: "cni0",
+		},
+		{
+			name:     "first with exclusions",
+			policy:   InterfacePolicy{Exclude: []string{"cni*", "kube-ipvs*", "veth*"}},
+			expected: "eth0",
+		},
+		{
+			name:     "name regex matches the full name",
+			policy:   InterfacePolicy{Mode: InterfaceSelectName, Names: []*regexp.Regexp{regexp.MustCompile("^(?:bond0)$")}},
+			expected: "bond0",
+		},
+		{
+			name:     "cidr",
+			policy:   InterfacePolicy{Mode: InterfaceSelectCIDR, CIDRs: []netip.Prefix{netip.MustParsePrefix("172.20.0.0/16")}},
+			expected: "bond0.20",
+		},
+		{
+			name:       "default route",
+			policy:     InterfacePolicy{Mode: InterfaceSelectDefaultRoute},
+			routeIface: "bond0",
+			expected:   "bond0",
+		},
+		{
+			name:     "endpoint route",
+			policy:   InterfacePolicy{Mode: InterfaceSelectEndpointRoute},
+			target:   "172.20.0.5",
+			expected: "bond0.20",
+		},
+		{
+			name:    "excluded match",
+			policy:  InterfacePolicy{Mode: InterfaceSelectDefaultRoute, Exclude: []string{"bond*"}},
+			wantErr: true,
+		},
+		{
+			name:    "no match",
+			policy:  InterfacePolicy{Mode: InterfaceSelectCIDR, CIDRs: []netip.Prefix{netip.MustParsePrefix("10.99.0.0/16")}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var target netip.Addr
+			if tt.target != "" {
+				target = netip.MustParseAddr(tt.target)
+			}
+
+			selected, err := selectInterface(tt.policy, addrs, tt.routeIface, target)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %s", selected.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if selected.name != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, selected.name)
+			}
+		})
+	}
+}
+
+func TestEndpointHost(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		expected string
+	}{
+		{endpoint: "https://cp.example.com:6443", expected: "cp.example.com"},
+		{endpoint: "https://10.0.0.100:6443", expected: "10.0.0.100"},
+		{endpoint: "10.0.0.100:6443", expected: "10.0.0.100"},
+		{endpoint: "cp.example.com", expected: "cp.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			host, err := endpointHost(tt.endpoint)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if host != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, host)
+			}
+		})
+	}
+}
+
+func TestNewInterfacePolicy(t *testing.T) {
+	policy, err := NewInterfacePolicy(InterfaceSelectName, []string{"bond0|eth.*"}, []string{"172.20.1.0/16"},
+		[]string{"cni*"}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(policy.Names) != 1 || !policy.Names[0].MatchString("eth1") || policy.Names[0].MatchString("bond0.20") {
+		t.Errorf("expected names to match the full interface name, got %v", policy.Names)
+	}
+	if len(policy.CIDRs) != 1 || policy.CIDRs[0] != netip.MustParsePrefix("172.20.0.0/16") {
+		t.Errorf("expected masked CIDR 172.20.0.0/16, got %v", policy.CIDRs)
+	}
+
+	_, err = NewInterfacePolicy(InterfaceSelectName, []string{"eth(", "bond0"}, []string{"172.20.0.0"},
+		[]string{"cni["}, "")
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	for _, invalid := range []string{"eth(", "172.20.0.0", "cni["} {
+		if !strings.Contains(err.Error(), invalid) {
+			t.Errorf("expected error to mention %s, got: %v", invalid, err)
+		}
+	}
+}
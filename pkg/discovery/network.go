@@ -5,9 +5,10 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
-	"net"
+	"iter"
 	"net/netip"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/cosi-project/runtime/pkg/safe"
@@ -25,6 +26,10 @@ type NetworkInfo struct {
 	Gateway netip.Addr
 	// LinkName is the network interface name
 	LinkName string
+	// LocalAddrs are the IPv4 addresses of all local interfaces
+	LocalAddrs []netip.Addr
+	// ConnectedSubnets are the networks of all local interfaces that are not excluded
+	ConnectedSubnets []netip.Prefix
 }
 
 // GetNetworkInfoFromCOSI retrieves network configuration from Talos COSI state.
@@ -76,86 +81,16 @@ func GetNetworkInfoFromCOSI(ctx context.Context, st state.State) (*NetworkInfo,
 	return info, nil
 }
 
-// GetNetworkInfo retrieves network configuration using Go's net package.
-// This is used instead of COSI when COSI access is not available.
-func GetNetworkInfo() (*NetworkInfo, error) {
-	interfaces, err := net.Interfaces()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get network interfaces: %w", err)
-	}
-
-	var info *NetworkInfo
-
-	for _, iface := range interfaces {
-		// Skip loopback and down interfaces
-		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
-			continue
-		}
-
-		addrs, err := iface.Addrs()
-		if err != nil {
-			continue
-		}
-
-		for _, addr := range addrs {
-			ipNet, ok := addr.(*net.IPNet)
-			if !ok {
-				continue
-			}
-
-			ip := ipNet.IP.To4()
-			if ip == nil {
-				continue // Skip IPv6
-			}
-
-			// Skip loopback and link-local
-			if ip.IsLoopback() || ip.IsLinkLocalUnicast() {
-				continue
-			}
-
-			netipAddr, ok := netip.AddrFromSlice(ip)
-			if !ok {
-				continue
-			}
-
-			ones, _ := ipNet.Mask.Size()
-			prefix := netip.PrefixFrom(netipAddr, ones)
-
-			info = &NetworkInfo{
-				LocalIP:  netipAddr,
-				CIDR:     prefix.Masked(),
-				LinkName: iface.Name,
-			}
-			break
-		}
-		if info != nil {
-			break
-		}
-	}
-
-	if info == nil {
-		return nil, fmt.Errorf("no suitable network address found")
-	}
-
-	// Get default gateway from /proc/net/route
-	gateway, err := getDefaultGateway()
-	if err == nil {
-		info.Gateway = gateway
-	}
-
-	return info, nil
-}
-
-// getDefaultGateway reads the default gateway from /proc/net/route.
+// getDefaultRoute reads the default route's interface and gateway from /proc/net/route.
 // Uses /host/proc when running in container to avoid conflicting with container's /proc.
-func getDefaultGateway() (netip.Addr, error) {
+func getDefaultRoute() (string, netip.Addr, error) {
 	// Try /host/proc first (container environment), then /proc (native)
 	file, err := os.Open("/host/proc/net/route")
 	if err != nil {
 		file, err = os.Open("/proc/net/route")
 	}
 	if err != nil {
-		return netip.Addr{}, err
+		return "", netip.Addr{}, err
 	}
 	defer func() { _ = file.Close() }()
 
@@ -169,7 +104,7 @@ func getDefaultGateway() (netip.Addr, error) {
 			continue
 		}
 
-		// Destination is field 1, Gateway is field 2
+		// Iface is field 0, Destination is field 1, Gateway is field 2
 		// Default route has destination 00000000
 		if fields[1] == "00000000" {
 			// Gateway is in hex, little-endian
@@ -182,33 +117,53 @@ func getDefaultGateway() (netip.Addr, error) {
 			binary.LittleEndian.PutUint32(gwBytes, gw)
 			addr, ok := netip.AddrFromSlice(gwBytes)
 			if ok {
-				return addr, nil
+				return fields[0], addr, nil
 			}
 		}
 	}
 
-	return netip.Addr{}, fmt.Errorf("no default gateway found")
+	return "", netip.Addr{}, fmt.Errorf("no default gateway found")
 }
 
-// GenerateIPsInCIDR generates all host IP addresses within a CIDR range,
-// excluding network and broadcast addresses.
-func GenerateIPsInCIDR(cidr netip.Prefix) []netip.Addr {
-	var ips []netip.Addr
-
-	addr := cidr.Addr()
-	bits := cidr.Bits()
-	hostBits := 32 - bits
-	numHosts := 1 << hostBits
-
-	// Skip network address (i=0) and broadcast address (i=numHosts-1)
-	for i := 1; i < numHosts-1; i++ {
-		ip := addToIP(addr, i)
-		if ip.IsValid() && cidr.Contains(ip) {
-			ips = append(ips, ip)
+// HostCount returns the number of host addresses in an IPv4 CIDR range.
+// Point-to-point /31 ranges and single-address /32 ranges have no network
+// and broadcast addresses, so all of their addresses are hosts.
+func HostCount(cidr netip.Prefix) int {
+	hostBits := 32 - cidr.Bits()
+	if hostBits <= 1 {
+		return 1 << hostBits
+	}
+
+	// Exclude network and broadcast addresses
+	return 1<<hostBits - 2
+}
+
+// hostAt returns the i-th host address of an IPv4 CIDR range.
+func hostAt(cidr netip.Prefix, i int) netip.Addr {
+	if cidr.Bits() < 31 {
+		i++ // Skip network address
+	}
+	return addToIP(cidr.Masked().Addr(), i)
+}
+
+// HostsInCIDR returns an iterator over the host addresses within an IPv4
+// CIDR range (see HostCount). Addresses are generated on demand, so
+// large ranges do not allocate.
+func HostsInCIDR(cidr netip.Prefix) iter.Seq[netip.Addr] {
+	return func(yield func(netip.Addr) bool) {
+		for i := range HostCount(cidr) {
+			if !yield(hostAt(cidr, i)) {
+				return
+			}
 		}
 	}
+}
 
-	return ips
+// GenerateIPsInCIDR generates all host IP addresses within a CIDR range,
+// excluding network and broadcast addresses. Prefer HostsInCIDR for ranges
+// that may be large.
+func GenerateIPsInCIDR(cidr netip.Prefix) []netip.Addr {
+	return slices.Collect(HostsInCIDR(cidr))
 }
 
 // addToIP adds an offset to an IPv4 address.
@@ -2,6 +2,7 @@ package discovery
 
 import (
 	"net/netip"
+	"slices"
 	"testing"
 )
 
@@ -107,3 +108,45 @@ func TestAddToIP(t *testing.T) {
 		})
 	}
 }
+
+func TestHostsInCIDR_PointToPoint(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		expected []string
+	}{
+		{cidr: "172.16.0.0/31", expected: []string{"172.16.0.0", "172.16.0.1"}},
+		{cidr: "172.16.0.7/32", expected: []string{"172.16.0.7"}},
+		{cidr: "172.16.0.5/30", expected: []string{"172.16.0.5", "172.16.0.6"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			var result []string
+			for ip := range HostsInCIDR(netip.MustParsePrefix(tt.cidr)) {
+				result = append(result, ip.String())
+			}
+			if !slices.Equal(result, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestHostsInCIDR_Large(t *testing.T) {
+	cidr := netip.MustParsePrefix("10.0.0.0/8")
+	if count := HostCount(cidr); count != 1<<24-2 {
+		t.Errorf("expected %d hosts for /8, got %d", 1<<24-2, count)
+	}
+
+	// Stopping early must not generate the remaining addresses
+	var first []string
+	for ip := range HostsInCIDR(cidr) {
+		first = append(first, ip.String())
+		if len(first) == 2 {
+			break
+		}
+	}
+	if !slices.Equal(first, []string{"10.0.0.1", "10.0.0.2"}) {
+		t.Errorf("unexpected first hosts %v", first)
+	}
+}
@@ -0,0 +1,197 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// ProbeOutcome classifies the result of probing an address.
+type ProbeOutcome string
+
+// Probe outcomes.
+const (
+	// ProbeFound means a Talos node was found
+	ProbeFound ProbeOutcome = "found"
+	// ProbeNoListener means the connection was refused: nothing listens on the Talos API port
+	ProbeNoListener ProbeOutcome = "no-listener"
+	// ProbeUnreachable means there is no route to the host
+	ProbeUnreachable ProbeOutcome = "unreachable"
+	// ProbeTimeout means the host did not answer in time
+	ProbeTimeout ProbeOutcome = "timeout"
+	// ProbeTLS means the TLS handshake failed, e.g. the port is not served by apid
+	ProbeTLS ProbeOutcome = "tls"
+	// ProbeAuth means the Talos API rejected the client
+	ProbeAuth ProbeOutcome = "auth"
+	// ProbeCOSIDenied means the Talos API answered, but reading resources was denied
+	ProbeCOSIDenied ProbeOutcome = "cosi-denied"
+	// ProbeOther is any other failure
+	ProbeOther ProbeOutcome = "other"
+)
+
+// ProbeOutcomes lists all probe outcomes in display order.
+var ProbeOutcomes = []ProbeOutcome{ProbeFound, ProbeNoListener, ProbeUnreachable, ProbeTimeout,
+	ProbeTLS, ProbeAuth, ProbeCOSIDenied, ProbeOther}
+
+// ProbeError is a classified probe failure.
+type ProbeError struct {
+	// Outcome is the failure class
+	Outcome ProbeOutcome
+	// Err is the underlying error
+	Err error
+}
+
+// Error implements error.
+func (e *ProbeError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Outcome, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ProbeError) Unwrap() error {
+	return e.Err
+}
+
+// newProbeError classifies err. cosi marks errors from reading COSI
+// resources, where a rejection means the read was denied.
+func newProbeError(err error, cosi bool) *ProbeError {
+	outcome := classifyProbeError(err)
+	if cosi && outcome == ProbeAuth {
+		outcome = ProbeCOSIDenied
+	}
+
+	return &ProbeError{Outcome: outcome, Err: err}
+}
+
+// classifyProbeError maps network, TLS and gRPC errors to a probe outcome.
+// gRPC reports connection failures as Unavailable with the cause in the
+// message, so the message is inspected as a fallback.
+func classifyProbeError(err error) ProbeOutcome {
+	var (
+		certErr    *tls.CertificateVerificationError
+		headerErr  tls.RecordHeaderError
+		unknownErr x509.UnknownAuthorityError
+	)
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err):
+		return ProbeTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ProbeNoListener
+	case errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH):
+		return ProbeUnreachable
+	case errors.As(err, &certErr) || errors.As(err, &headerErr) || errors.As(err, &unknownErr):
+		return ProbeTLS
+	}
+
+	st, ok := grpcstatus.FromError(err)
+	if !ok {
+		return ProbeOther
+	}
+
+	switch st.Code() {
+	case codes.DeadlineExceeded:
+		return ProbeTimeout
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return ProbeAuth
+	case codes.Unavailable:
+		msg := st.Message()
+		switch {
+		case strings.Contains(msg, "connection refused"):
+			return ProbeNoListener
+		case strings.Contains(msg, "no route to host"), strings.Contains(msg, "network is unreachable"):
+			return ProbeUnreachable
+		case strings.Contains(msg, "i/o timeout"), strings.Contains(msg, "deadline exceeded"):
+			return ProbeTimeout
+		case strings.Contains(msg, "handshake"), strings.Contains(msg, "tls:"), strings.Contains(msg, "x509"):
+			return ProbeTLS
+		}
+	}
+
+	return ProbeOther
+}
+
+// ScanStats aggregates the probe outcomes of a scan.
+type ScanStats struct {
+	mu sync.Mutex
+
+	counts  map[ProbeOutcome]int
+	failure func(ip netip.Addr, err *ProbeError)
+}
+
+// NewScanStats creates scan stats. failure is called for every failed probe,
+// e.g. to log the details at debug level; it may be nil.
+func NewScanStats(failure func(ip netip.Addr, err *ProbeError)) *ScanStats {
+	return &ScanStats{counts: make(map[ProbeOutcome]int), failure: failure}
+}
+
+// record counts the outcome of probing ip. A nil err counts as found;
+// probes cancelled because the scan stopped are not counted. Recording on
+// nil ScanStats does nothing.
+func (s *ScanStats) record(ip netip.Addr, err error) {
+	if s == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+
+	outcome := ProbeFound
+	var probeErr *ProbeError
+	if err != nil {
+		if !errors.As(err, &probeErr) {
+			probeErr = newProbeError(err, false)
+		}
+		outcome = probeErr.Outcome
+	}
+
+	s.mu.Lock()
+	s.counts[outcome]++
+	s.mu.Unlock()
+
+	if probeErr != nil && s.failure != nil {
+		s.failure(ip, probeErr)
+	}
+}
+
+// Counts returns the number of probes per outcome. Outcomes that did not
+// occur are omitted.
+func (s *ScanStats) Counts() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]int, len(s.counts))
+	for outcome, count := range s.counts {
+		counts[string(outcome)] = count
+	}
+
+	return counts
+}
+
+// String renders the counts in display order, e.g. "found=2 timeout=250".
+func (s *ScanStats) String() string {
+	return FormatProbeCounts(s.Counts())
+}
+
+// FormatProbeCounts renders probe counts in display order, e.g.
+// "found=2 timeout=250", or "none" if there are none.
+func FormatProbeCounts(counts map[string]int) string {
+	var parts []string
+	for _, outcome := range ProbeOutcomes {
+		if count := counts[string(outcome)]; count > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", outcome, count))
+		}
+	}
+
+	if len(parts) == 0 {
+		return "none"
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,106 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"syscall"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func TestClassifyProbeError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		cosi     bool
+		expected ProbeOutcome
+	}{
+		{
+			name:     "connection refused",
+			err:      &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+			expected: ProbeNoListener,
+		},
+		{
+			name:     "no route to host",
+			err:      &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)},
+			expected: ProbeUnreachable,
+		},
+		{
+			name:     "deadline exceeded",
+			err:      fmt.Errorf("probe: %w", context.DeadlineExceeded),
+			expected: ProbeTimeout,
+		},
+		{
+			name:     "grpc connection refused",
+			err:      grpcstatus.Error(codes.Unavailable, "connection error: desc = \"transport: Error while dialing: dial tcp 10.0.0.2:50000: connect: connection refused\""),
+			expected: ProbeNoListener,
+		},
+		{
+			name:     "grpc handshake failure",
+			err:      grpcstatus.Error(codes.Unavailable, "connection error: desc = \"transport: authentication handshake failed: tls: first record does not look like a TLS handshake\""),
+			expected: ProbeTLS,
+		},
+		{
+			name:     "grpc timeout",
+			err:      grpcstatus.Error(codes.DeadlineExceeded, "context deadline exceeded"),
+			expected: ProbeTimeout,
+		},
+		{
+			name:     "version rejected",
+			err:      grpcstatus.Error(codes.Unauthenticated, "missing client certificate"),
+			expected: ProbeAuth,
+		},
+		{
+			name:     "cosi read denied",
+			err:      grpcstatus.Error(codes.PermissionDenied, "not authorized"),
+			cosi:     true,
+			expected: ProbeCOSIDenied,
+		},
+		{
+			name:     "other",
+			err:      errors.New("unexpected"),
+			expected: ProbeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if outcome := newProbeError(tt.err, tt.cosi).Outcome; outcome != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, outcome)
+			}
+		})
+	}
+}
+
+func TestScanStats(t *testing.T) {
+	var failed []string
+	stats := NewScanStats(func(ip netip.Addr, err *ProbeError) {
+		failed = append(failed, ip.String()+" "+string(err.Outcome))
+	})
+
+	stats.record(netip.MustParseAddr("10.0.0.2"), nil)
+	stats.record(netip.MustParseAddr("10.0.0.3"), newProbeError(context.DeadlineExceeded, false))
+	stats.record(netip.MustParseAddr("10.0.0.4"), newProbeError(context.DeadlineExceeded, false))
+	stats.record(netip.MustParseAddr("10.0.0.5"), grpcstatus.Error(codes.Unauthenticated, "denied"))
+	stats.record(netip.MustParseAddr("10.0.0.6"), context.Canceled)
+
+	if s := stats.String(); s != "found=1 timeout=2 auth=1" {
+		t.Errorf("unexpected stats %q", s)
+	}
+	if len(failed) != 3 || failed[2] != "10.0.0.5 auth" {
+		t.Errorf("unexpected failures %v", failed)
+	}
+
+	var disabled *ScanStats
+	disabled.record(netip.MustParseAddr("10.0.0.2"), nil)
+
+	if s := FormatProbeCounts(nil); s != "none" {
+		t.Errorf("expected none, got %q", s)
+	}
+}
@@ -0,0 +1,133 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/netip"
+	"sync"
+	"time"
+
+	talosclient "github.com/siderolabs/talos/pkg/machinery/client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// PeerPool keeps authenticated gRPC connections to the known control plane
+// peers open between loop iterations, so rediscovery and peer queries during
+// election and safety checks do not reconnect every time. Connections are
+// dialed on first use and only to members of the pool.
+type PeerPool struct {
+	mu sync.Mutex
+
+	tlsConfig *tls.Config
+	// clients maps the members to their connection (nil until first use)
+	clients map[netip.Addr]*talosclient.Client
+}
+
+// NewPeerPool creates an empty peer pool. Connections authenticate with
+// tlsConfig, e.g. the admin credentials issued from the machine CA.
+func NewPeerPool(tlsConfig *tls.Config) *PeerPool {
+	return &PeerPool{tlsConfig: tlsConfig, clients: make(map[netip.Addr]*talosclient.Client)}
+}
+
+// Retain makes peers the members of the pool. Connections to former members
+// are closed; new members are dialed on first use.
+func (p *PeerPool) Retain(peers []netip.Addr) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	members := make(map[netip.Addr]*talosclient.Client, len(peers))
+	for _, peer := range peers {
+		members[peer] = p.clients[peer]
+		delete(p.clients, peer)
+	}
+
+	for _, client := range p.clients {
+		if client != nil {
+			_ = client.Close()
+		}
+	}
+
+	p.clients = members
+}
+
+// Client returns the pooled client for peer, dialing it on first use.
+// Returns false if peer is not a member of the pool.
+func (p *PeerPool) Client(peer netip.Addr) (*talosclient.Client, bool) {
+	if p == nil {
+		return nil, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	client, ok := p.clients[peer]
+	if !ok {
+		return nil, false
+	}
+	if client != nil {
+		return client, true
+	}
+
+	// The connection is established lazily, so this only fails on invalid options
+	client, err := talosclient.New(context.Background(),
+		talosclient.WithEndpoints(netip.AddrPortFrom(peer, TalosAPIPort).String()),
+		talosclient.WithTLSConfig(p.tlsConfig),
+		talosclient.WithGRPCDialOptions(
+			grpc.WithTransportCredentials(credentials.NewTLS(p.tlsConfig)),
+		),
+	)
+	if err != nil {
+		return nil, false
+	}
+
+	p.clients[peer] = client
+	return client, true
+}
+
+// Reset closes the connection to peer after a failed request, so the next
+// request dials afresh instead of waiting out gRPC's reconnect backoff. peer
+// stays a member. Resetting on a nil PeerPool does nothing.
+func (p *PeerPool) Reset(peer netip.Addr) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if client := p.clients[peer]; client != nil {
+		_ = client.Close()
+		p.clients[peer] = nil
+	}
+}
+
+// Close closes all connections and empties the pool.
+func (p *PeerPool) Close() {
+	p.Retain(nil)
+}
+
+// probe probes ip over its pooled connection if it is a member of the pool,
+// and with a one-off discovery client otherwise. Members that reject the
+// pool's credentials, e.g. nodes of another cluster, are probed like unknown
+// nodes.
+func (p *PeerPool) probe(ctx context.Context, ip netip.Addr, timeout time.Duration) (*DiscoveredNode, error) {
+	client, ok := p.Client(ip)
+	if !ok {
+		return probeTalosNode(ctx, ip, timeout)
+	}
+
+	node, err := probeClient(ctx, client, ip, timeout)
+	if err == nil || errors.Is(err, context.Canceled) {
+		return node, err
+	}
+	p.Reset(ip)
+
+	var probeErr *ProbeError
+	if errors.As(err, &probeErr) && (probeErr.Outcome == ProbeTLS || probeErr.Outcome == ProbeAuth) {
+		return probeTalosNode(ctx, ip, timeout)
+	}
+
+	return nil, err
+}
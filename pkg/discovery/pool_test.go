@@ -0,0 +1,80 @@
+package discovery
+
+import (
+	"crypto/tls"
+	"net/netip"
+	"testing"
+)
+
+func TestPeerPool_Client(t *testing.T) {
+	pool := NewPeerPool(&tls.Config{MinVersion: tls.VersionTLS12})
+	defer pool.Close()
+
+	member := netip.MustParseAddr("192.0.2.10")
+	other := netip.MustParseAddr("192.0.2.20")
+	pool.Retain([]netip.Addr{member})
+
+	first, ok := pool.Client(member)
+	if !ok || first == nil {
+		t.Fatalf("Client(%s) = %v, %v, want a client", member, first, ok)
+	}
+
+	second, _ := pool.Client(member)
+	if second != first {
+		t.Errorf("Client(%s) dialed a new client, want the pooled one", member)
+	}
+
+	if _, ok := pool.Client(other); ok {
+		t.Errorf("Client(%s) returned a client for a non-member", other)
+	}
+}
+
+func TestPeerPool_Reset(t *testing.T) {
+	pool := NewPeerPool(&tls.Config{MinVersion: tls.VersionTLS12})
+	defer pool.Close()
+
+	member := netip.MustParseAddr("192.0.2.10")
+	pool.Retain([]netip.Addr{member})
+
+	first, _ := pool.Client(member)
+	pool.Reset(member)
+
+	second, ok := pool.Client(member)
+	if !ok {
+		t.Fatalf("Client(%s) after Reset returned no client, want member kept", member)
+	}
+	if second == first {
+		t.Errorf("Client(%s) after Reset returned the closed client", member)
+	}
+}
+
+func TestPeerPool_Retain(t *testing.T) {
+	pool := NewPeerPool(&tls.Config{MinVersion: tls.VersionTLS12})
+	defer pool.Close()
+
+	kept := netip.MustParseAddr("192.0.2.10")
+	dropped := netip.MustParseAddr("192.0.2.20")
+	pool.Retain([]netip.Addr{kept, dropped})
+
+	keptClient, _ := pool.Client(kept)
+	_, _ = pool.Client(dropped)
+
+	pool.Retain([]netip.Addr{kept})
+
+	if client, _ := pool.Client(kept); client != keptClient {
+		t.Errorf("Retain redialed %s, want the connection kept", kept)
+	}
+	if _, ok := pool.Client(dropped); ok {
+		t.Errorf("Client(%s) returned a client after it left the pool", dropped)
+	}
+}
+
+func TestPeerPool_Nil(t *testing.T) {
+	var pool *PeerPool
+	ip := netip.MustParseAddr("192.0.2.10")
+
+	if _, ok := pool.Client(ip); ok {
+		t.Errorf("Client on nil pool returned a client")
+	}
+	pool.Reset(ip)
+}
@@ -0,0 +1,82 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// arpFlagComplete marks a resolved entry in /proc/net/arp.
+const arpFlagComplete = 0x2
+
+// dialAPIPort opens and closes a TCP connection to the Talos API port of ip
+// within timeout. It is a cheap check run before the full Talos probe, so
+// that hosts without apid are skipped quickly.
+func dialAPIPort(ctx context.Context, ip netip.Addr, timeout time.Duration) error {
+	dialer := net.Dialer{Timeout: timeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", netip.AddrPortFrom(ip, TalosAPIPort).String())
+	if err != nil {
+		return newProbeError(err, false)
+	}
+	_ = conn.Close()
+
+	return nil
+}
+
+// NeighborAddrs returns the IPv4 addresses of resolved entries in the
+// kernel's neighbor (ARP) table. These hosts were recently active, so they
+// are probed first. Returns nil if the table is not readable.
+// Uses /host/proc when running in container to avoid conflicting with container's /proc.
+func NeighborAddrs() []netip.Addr {
+	// Try /host/proc first (container environment), then /proc (native)
+	file, err := os.Open("/host/proc/net/arp")
+	if err != nil {
+		file, err = os.Open("/proc/net/arp")
+	}
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = file.Close() }()
+
+	return parseNeighbors(file)
+}
+
+// parseNeighbors parses the /proc/net/arp format:
+//
+//	IP address       HW type     Flags       HW address            Mask     Device
+//	10.0.0.1         0x1         0x2         52:54:00:12:34:56     *        eth0
+func parseNeighbors(r io.Reader) []netip.Addr {
+	var addrs []netip.Addr
+
+	scanner := bufio.NewScanner(r)
+	// Skip header line
+	scanner.Scan()
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32)
+		if err != nil || flags&arpFlagComplete == 0 {
+			continue
+		}
+
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil || !addr.Is4() {
+			continue
+		}
+
+		addrs = append(addrs, addr)
+	}
+
+	return addrs
+}
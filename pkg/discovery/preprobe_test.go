@@ -0,0 +1,48 @@
+package discovery
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseNeighbors(t *testing.T) {
+	table := `IP address       HW type     Flags       HW address            Mask     Device
+10.0.0.1         0x1         0x2         52:54:00:12:34:56     *        eth0
+10.0.0.12        0x1         0x0         00:00:00:00:00:00     *        eth0
+10.0.0.13        0x1         0x6         52:54:00:12:34:58     *        eth0
+garbage
+`
+
+	var result []string
+	for _, addr := range parseNeighbors(strings.NewReader(table)) {
+		result = append(result, addr.String())
+	}
+
+	expected := []string{"10.0.0.1", "10.0.0.13"}
+	if !slices.Equal(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestScanRangesForTalosNodes_PreProbe(t *testing.T) {
+	// Nothing listens on the Talos API port on loopback, so the pre-probe
+	// rejects every address without running the full Talos probe
+	ranges := []ScanRange{{CIDR: netip.MustParsePrefix("127.0.0.0/30")}}
+	nodes, err := ScanRangesForTalosNodes(t.Context(), ranges, nil, ScanOptions{
+		Timeout:             time.Second,
+		Concurrency:         2,
+		PreProbe:            true,
+		PreProbeTimeout:     100 * time.Millisecond,
+		PreProbeConcurrency: 4,
+		Seed:                []netip.Addr{netip.MustParseAddr("127.0.0.2"), netip.MustParseAddr("10.0.0.1")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes) != 0 {
+		t.Errorf("expected no nodes, got %v", nodes)
+	}
+}
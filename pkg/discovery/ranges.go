@@ -0,0 +1,379 @@
+package discovery
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"iter"
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+)
+
+// Oversized range handling modes.
+const (
+	// OversizedRefuse skips ranges with more hosts than the limit
+	OversizedRefuse = "refuse"
+	// OversizedSample scans a different sample of at most the limit of hosts each sweep
+	OversizedSample = "sample"
+)
+
+// OversizedModes lists all oversized range handling modes.
+var OversizedModes = []string{OversizedRefuse, OversizedSample}
+
+// scanRangeExcludeSeparator separates a scan range CIDR from its exclusions,
+// e.g. 10.1.0.0/16!10.1.5.0/24.
+const scanRangeExcludeSeparator = "!"
+
+// ScanRange is an IPv4 network to scan for Talos nodes, minus excluded subranges.
+type ScanRange struct {
+	// CIDR is the network to scan
+	CIDR netip.Prefix
+	// Exclude lists subranges of CIDR that are not scanned
+	Exclude []netip.Prefix
+	// Sample limits each sweep to this many evenly spread hosts (0 scans all hosts)
+	Sample int
+}
+
+// ParseScanRange parses a scan range of the form CIDR[!EXCLUDE...], e.g.
+// 10.1.0.0/16!10.1.5.0/24!10.1.6.0/26.
+func ParseScanRange(s string) (ScanRange, error) {
+	parts := strings.Split(strings.TrimSpace(s), scanRangeExcludeSeparator)
+
+	cidr, err := parseIPv4Prefix(parts[0])
+	if err != nil {
+		return ScanRange{}, fmt.Errorf("invalid scan range %q: %w", s, err)
+	}
+
+	r := ScanRange{CIDR: cidr}
+	for _, part := range parts[1:] {
+		exclude, err := parseIPv4Prefix(part)
+		if err != nil {
+			return ScanRange{}, fmt.Errorf("invalid exclusion in scan range %q: %w", s, err)
+		}
+		if !cidr.Overlaps(exclude) {
+			return ScanRange{}, fmt.Errorf("exclusion %s is outside of scan range %s", exclude, cidr)
+		}
+		r.Exclude = append(r.Exclude, exclude)
+	}
+
+	return r, nil
+}
+
+// parseIPv4Prefix parses an IPv4 CIDR. A bare address is treated as a /32.
+func parseIPv4Prefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+
+	var prefix netip.Prefix
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		prefix = p.Masked()
+	} else {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+
+	if !prefix.Addr().Is4() {
+		return netip.Prefix{}, fmt.Errorf("%s is not an IPv4 network", prefix)
+	}
+
+	return prefix, nil
+}
+
+// String renders the range in the form accepted by ParseScanRange.
+func (r ScanRange) String() string {
+	parts := []string{r.CIDR.String()}
+	for _, exclude := range r.Exclude {
+		parts = append(parts, exclude.String())
+	}
+	return strings.Join(parts, scanRangeExcludeSeparator)
+}
+
+// Contains reports whether ip is within the range and not excluded.
+func (r ScanRange) Contains(ip netip.Addr) bool {
+	return r.CIDR.Contains(ip) && !slices.ContainsFunc(r.Exclude, func(exclude netip.Prefix) bool {
+		return exclude.Contains(ip)
+	})
+}
+
+// HostCount returns the number of host addresses in the range, before
+// exclusions.
+func (r ScanRange) HostCount() int {
+	return HostCount(r.CIDR)
+}
+
+// Hosts returns an iterator over the host addresses within the range that
+// are not excluded. If Sample is set, only a sample of evenly spread hosts
+// is returned, starting at a random offset so that consecutive sweeps cover
+// different hosts.
+func (r ScanRange) Hosts() iter.Seq[netip.Addr] {
+	count := r.HostCount()
+
+	stride, offset := 1, 0
+	if r.Sample > 0 && count > r.Sample {
+		stride = (count + r.Sample - 1) / r.Sample
+		offset = rand.IntN(stride)
+	}
+
+	return func(yield func(netip.Addr) bool) {
+		for i := offset; i < count; i += stride {
+			ip := hostAt(r.CIDR, i)
+			if r.Contains(ip) && !yield(ip) {
+				return
+			}
+		}
+	}
+}
+
+// LimitScanRanges applies the maxHosts limit to ranges with more hosts.
+// With OversizedSample such ranges are sampled; with OversizedRefuse they
+// are dropped and returned as refused.
+func LimitScanRanges(ranges []ScanRange, maxHosts int, mode string) (limited, refused []ScanRange) {
+	for _, r := range ranges {
+		switch {
+		case r.HostCount() <= maxHosts:
+			limited = append(limited, r)
+		case mode == OversizedSample:
+			r.Sample = maxHosts
+			limited = append(limited, r)
+		default:
+			refused = append(refused, r)
+		}
+	}
+
+	return limited, refused
+}
+
+// ScanRanges returns the ranges to scan. configured ranges are scanned as
+// given; with connected, every subnet connected to a local interface is
+// added. Without either, the local network netInfo.CIDR is scanned.
+func ScanRanges(netInfo *NetworkInfo, configured []ScanRange, connected bool) []ScanRange {
+	ranges := slices.Clone(configured)
+
+	if connected {
+		for _, subnet := range netInfo.ConnectedSubnets {
+			if !slices.ContainsFunc(ranges, func(r ScanRange) bool { return r.CIDR == subnet }) {
+				ranges = append(ranges, ScanRange{CIDR: subnet})
+			}
+		}
+	}
+
+	if len(ranges) == 0 {
+		ranges = append(ranges, ScanRange{CIDR: netInfo.CIDR})
+	}
+
+	return ranges
+}
+
+// ScanOptions controls how ranges are scanned for Talos nodes.
+type ScanOptions struct {
+	// Timeout is the timeout for probing each node
+	Timeout time.Duration
+	// Concurrency is the maximum number of concurrent Talos probes across all ranges
+	Concurrency int
+	// PreProbe checks that the Talos API port accepts TCP connections before
+	// running the full Talos probe
+	PreProbe bool
+	// PreProbeTimeout is the TCP connect timeout of the pre-probe
+	PreProbeTimeout time.Duration
+	// PreProbeConcurrency is the maximum number of concurrent pre-probes
+	PreProbeConcurrency int
+	// Known lists the addresses of previously found peers, which are probed
+	// first if they are within a range
+	Known []netip.Addr
+	// Seed lists addresses that are probed next if they are within a range,
+	// e.g. the neighbor table entries
+	Seed []netip.Addr
+	// SeedOnly probes only the Known and Seed addresses instead of sweeping the ranges
+	SeedOnly bool
+	// ConnectTimeouts adapts the pre-probe timeout per host (nil uses PreProbeTimeout).
+	// The Talos probe of a host that accepted the connection always gets Timeout
+	ConnectTimeouts *AdaptiveTimeouts
+	// Limiter limits the rate of connection attempts across all ranges (nil is unlimited)
+	Limiter *rate.Limiter
+	// Stats aggregates the probe outcomes (nil disables)
+	Stats *ScanStats
+	// Pool probes its members over their pooled connections (nil probes
+	// every address with a one-off client)
+	Pool *PeerPool
+}
+
+// ScanRangesForTalosNodes scans several ranges for Talos nodes and returns
+// them once the scan is complete. A node reachable on several scanned
+// addresses is reported once, on its address in the earliest range.
+func ScanRangesForTalosNodes(ctx context.Context, ranges []ScanRange, skip []netip.Addr,
+	opts ScanOptions) ([]DiscoveredNode, error) {
+
+	return ScanRangesUntil(ctx, ranges, skip, opts, nil)
+}
+
+// ScanRangesUntil scans several ranges for Talos nodes like
+// ScanRangesForTalosNodes, but calls done with the nodes found so far
+// whenever a node is found. If done returns true, the scan stops early and
+// the nodes found so far are returned. done may be nil.
+func ScanRangesUntil(ctx context.Context, ranges []ScanRange, skip []netip.Addr,
+	opts ScanOptions, done func(nodes []DiscoveredNode) bool) ([]DiscoveredNode, error) {
+
+	var found []DiscoveredNode
+
+	err := StreamRangesForTalosNodes(ctx, ranges, skip, opts, func(node DiscoveredNode) bool {
+		found = append(found, node)
+		return done == nil || !done(orderNodes(ranges, found))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return orderNodes(ranges, found), nil
+}
+
+// StreamRangesForTalosNodes scans several ranges for Talos nodes in two
+// stages: a cheap TCP connect to the Talos API port finds responders (if
+// PreProbe is set), and only those get the full Talos probe, at most
+// Concurrency at a time across all ranges. Addresses in overlapping ranges
+// are probed once, and the local addresses in skip are never probed.
+//
+// found is called for every node as soon as it is probed, one call at a
+// time. Nodes reachable on several addresses are reported for each address.
+// If found returns false, the scan stops and the remaining probes are
+// cancelled.
+func StreamRangesForTalosNodes(ctx context.Context, ranges []ScanRange, skip []netip.Addr,
+	opts ScanOptions, found func(DiscoveredNode) bool) error {
+
+	var (
+		foundMu sync.Mutex
+		stopped bool
+	)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	probed := make(map[netip.Addr]bool)
+	for _, ip := range skip {
+		probed[ip] = true
+	}
+
+	probes, ctx := errgroup.WithContext(ctx)
+	probes.SetLimit(opts.Concurrency)
+
+	preProbes := new(errgroup.Group)
+	preProbes.SetLimit(max(opts.PreProbeConcurrency, opts.Concurrency))
+
+	schedule := func(ip netip.Addr, source DiscoverySource) {
+		probed[ip] = true
+
+		preProbes.Go(func() error {
+			if opts.PreProbe {
+				if opts.Limiter != nil && opts.Limiter.Wait(ctx) != nil {
+					return nil
+				}
+
+				start := time.Now()
+				if err := dialAPIPort(ctx, ip, opts.ConnectTimeouts.Timeout(ip, opts.PreProbeTimeout)); err != nil {
+					opts.Stats.record(ip, err)
+					return nil // Nothing listens on the Talos API port
+				}
+				opts.ConnectTimeouts.Observe(ip, time.Since(start))
+			}
+
+			probes.Go(func() error {
+				if opts.Limiter != nil && opts.Limiter.Wait(ctx) != nil {
+					return nil
+				}
+
+				node, err := opts.Pool.probe(ctx, ip, opts.Timeout)
+				opts.Stats.record(ip, err)
+				if err != nil {
+					return nil // Not a Talos node or unreachable, counted in the stats
+				}
+				node.Source = source
+
+				foundMu.Lock()
+				defer foundMu.Unlock()
+				if !stopped && !found(*node) {
+					stopped = true
+					cancel()
+				}
+				return nil
+			})
+			return nil
+		})
+	}
+
+	// Known peers and seeded addresses are likely alive, so they are probed first
+	seeds := []struct {
+		ips    []netip.Addr
+		source DiscoverySource
+	}{{opts.Known, SourceCache}, {opts.Seed, SourceSeed}}
+	for _, seed := range seeds {
+		for _, ip := range seed.ips {
+			if !probed[ip] && slices.ContainsFunc(ranges, func(r ScanRange) bool { return r.Contains(ip) }) {
+				schedule(ip, seed.source)
+			}
+		}
+	}
+
+	for _, r := range ranges {
+		if opts.SeedOnly {
+			break
+		}
+
+		for ip := range r.Hosts() {
+			if ctx.Err() != nil {
+				break
+			}
+			if !probed[ip] {
+				schedule(ip, SourceScan)
+			}
+		}
+	}
+
+	_ = preProbes.Wait()
+	return probes.Wait()
+}
+
+// orderNodes sorts nodes by the earliest range containing their address,
+// then by address, and drops nodes found on several addresses.
+func orderNodes(ranges []ScanRange, nodes []DiscoveredNode) []DiscoveredNode {
+	rangeIndex := func(node DiscoveredNode) int {
+		return slices.IndexFunc(ranges, func(r ScanRange) bool { return r.Contains(node.IP) })
+	}
+
+	ordered := slices.Clone(nodes)
+	slices.SortFunc(ordered, func(a, b DiscoveredNode) int {
+		return cmp.Or(cmp.Compare(rangeIndex(a), rangeIndex(b)), a.IP.Compare(b.IP))
+	})
+
+	return dedupeNodes(ordered)
+}
+
+// dedupeNodes drops nodes whose machine UUID (or node ID, if the UUID is
+// unknown) was already seen, keeping the first occurrence. Nodes without
+// either are always kept.
+func dedupeNodes(nodes []DiscoveredNode) []DiscoveredNode {
+	seen := make(map[string]bool, len(nodes))
+
+	return slices.DeleteFunc(nodes, func(node DiscoveredNode) bool {
+		key := cmp.Or(node.MachineUUID, node.NodeID)
+		if key == "" {
+			return false
+		}
+		if seen[key] {
+			return true
+		}
+		seen[key] = true
+		return false
+	})
+}
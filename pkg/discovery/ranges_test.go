@@ -0,0 +1,161 @@
+package discovery
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestParseScanRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		count    int
+		wantErr  bool
+	}{
+		{input: "10.1.0.0/24", expected: "10.1.0.0/24", count: 254},
+		{input: "10.1.0.7/24", expected: "10.1.0.0/24", count: 254},
+		{input: "10.1.0.0/24!10.1.0.0/26", expected: "10.1.0.0/24!10.1.0.0/26", count: 191},
+		{input: "10.1.0.0/24!10.1.0.10!10.1.0.20", expected: "10.1.0.0/24!10.1.0.10/32!10.1.0.20/32", count: 252},
+		{input: "10.1.0.0/24!10.2.0.0/24", wantErr: true},
+		{input: "fd00::/64", wantErr: true},
+		{input: "10.1.0.0/33", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			r, err := ParseScanRange(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %s", r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if r.String() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, r)
+			}
+			if count := len(slices.Collect(r.Hosts())); count != tt.count {
+				t.Errorf("expected %d addresses, got %d", tt.count, count)
+			}
+		})
+	}
+}
+
+func TestScanRanges(t *testing.T) {
+	netInfo := &NetworkInfo{
+		CIDR: netip.MustParsePrefix("10.1.0.0/24"),
+		ConnectedSubnets: []netip.Prefix{
+			netip.MustParsePrefix("10.1.0.0/24"),
+			netip.MustParsePrefix("10.9.0.0/24"),
+		},
+	}
+	configured := []ScanRange{{CIDR: netip.MustParsePrefix("10.1.0.0/24"),
+		Exclude: []netip.Prefix{netip.MustParsePrefix("10.1.0.128/25")}}}
+
+	tests := []struct {
+		name       string
+		configured []ScanRange
+		connected  bool
+		expected   []string
+	}{
+		{name: "local network", expected: []string{"10.1.0.0/24"}},
+		{name: "configured", configured: configured, expected: []string{"10.1.0.0/24!10.1.0.128/25"}},
+		{name: "connected", connected: true, expected: []string{"10.1.0.0/24", "10.9.0.0/24"}},
+		{
+			name:       "configured ranges take precedence over connected subnets",
+			configured: configured,
+			connected:  true,
+			expected:   []string{"10.1.0.0/24!10.1.0.128/25", "10.9.0.0/24"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result []string
+			for _, r := range ScanRanges(netInfo, tt.configured, tt.connected) {
+				result = append(result, r.String())
+			}
+			if !slices.Equal(result, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestDedupeNodes(t *testing.T) {
+	nodes := []DiscoveredNode{
+		{IP: netip.MustParseAddr("10.1.0.2"), MachineUUID: "a"},
+		{IP: netip.MustParseAddr("10.1.0.3")},
+		{IP: netip.MustParseAddr("10.2.0.2"), MachineUUID: "a"},
+		{IP: netip.MustParseAddr("10.2.0.3")},
+		{IP: netip.MustParseAddr("10.1.0.4"), NodeID: "b"},
+		{IP: netip.MustParseAddr("10.2.0.4"), NodeID: "b"},
+	}
+
+	var result []string
+	for _, node := range dedupeNodes(nodes) {
+		result = append(result, node.IP.String())
+	}
+
+	expected := []string{"10.1.0.2", "10.1.0.3", "10.2.0.3", "10.1.0.4"}
+	if !slices.Equal(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestLimitScanRanges(t *testing.T) {
+	ranges := []ScanRange{
+		{CIDR: netip.MustParsePrefix("10.1.0.0/24")},
+		{CIDR: netip.MustParsePrefix("10.0.0.0/8")},
+	}
+
+	limited, refused := LimitScanRanges(ranges, 1000, OversizedRefuse)
+	if len(limited) != 1 || len(refused) != 1 || refused[0].CIDR != ranges[1].CIDR {
+		t.Errorf("expected the /8 to be refused, got limited %v and refused %v", limited, refused)
+	}
+
+	limited, refused = LimitScanRanges(ranges, 1000, OversizedSample)
+	if len(limited) != 2 || len(refused) != 0 {
+		t.Fatalf("expected both ranges to be scanned, got limited %v and refused %v", limited, refused)
+	}
+
+	sample := slices.Collect(limited[1].Hosts())
+	if len(sample) == 0 || len(sample) > 1000 {
+		t.Errorf("expected a sample of at most 1000 hosts, got %d", len(sample))
+	}
+	for _, ip := range sample {
+		if !ranges[1].CIDR.Contains(ip) {
+			t.Errorf("sampled host %s is outside of %s", ip, ranges[1].CIDR)
+		}
+	}
+}
+
+func TestOrderNodes(t *testing.T) {
+	ranges := []ScanRange{
+		{CIDR: netip.MustParsePrefix("10.2.0.0/24")},
+		{CIDR: netip.MustParsePrefix("10.1.0.0/24")},
+	}
+	// In arrival order; machine a is reachable in both ranges
+	nodes := []DiscoveredNode{
+		{IP: netip.MustParseAddr("10.1.0.9"), MachineUUID: "a"},
+		{IP: netip.MustParseAddr("10.1.0.3"), MachineUUID: "b"},
+		{IP: netip.MustParseAddr("10.2.0.7"), MachineUUID: "a"},
+		{IP: netip.MustParseAddr("10.2.0.5"), MachineUUID: "c"},
+	}
+
+	var result []string
+	for _, node := range orderNodes(ranges, nodes) {
+		result = append(result, node.IP.String())
+	}
+
+	expected := []string{"10.2.0.5", "10.2.0.7", "10.1.0.3"}
+	if !slices.Equal(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	if nodes[0].IP.String() != "10.1.0.9" {
+		t.Errorf("expected the input to be left unchanged, got %v", nodes)
+	}
+}
@@ -6,16 +6,19 @@ import (
 	"fmt"
 	"net/netip"
 	"os"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/cosi-project/runtime/pkg/resource"
 	"github.com/cosi-project/runtime/pkg/safe"
 	talosclient "github.com/siderolabs/talos/pkg/machinery/client"
+	clusterres "github.com/siderolabs/talos/pkg/machinery/resources/cluster"
 	configres "github.com/siderolabs/talos/pkg/machinery/resources/config"
+	hardwareres "github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+	k8sres "github.com/siderolabs/talos/pkg/machinery/resources/k8s"
+	networkres "github.com/siderolabs/talos/pkg/machinery/resources/network"
 	runtimeres "github.com/siderolabs/talos/pkg/machinery/resources/runtime"
-	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
@@ -23,18 +26,66 @@ import (
 const (
 	// TalosAPIPort is the default port for Talos API.
 	TalosAPIPort = 50000
+
+	// PriorityLabel is the node label (or annotation) holding a node's
+	// leader election priority for the priority strategy.
+	PriorityLabel = "autobootstrap.kommodity.io/priority"
 )
 
 // DiscoveredNode represents a Talos node found during network scanning.
 type DiscoveredNode struct {
 	// IP is the node's IP address
-	IP netip.Addr
+	IP netip.Addr `json:"ip"`
 	// IsControlPlane indicates if this is a control plane node
-	IsControlPlane bool
+	IsControlPlane bool `json:"isControlPlane"`
 	// CreationTime is the node's boot time (used for leader election)
-	CreationTime time.Time
+	CreationTime time.Time `json:"creationTime"`
 	// Hostname is the node's hostname
-	Hostname string
+	Hostname string `json:"hostname"`
+	// MachineUUID is the node's SMBIOS system UUID (empty if unknown)
+	MachineUUID string `json:"machineUUID,omitempty"`
+	// Priority is the election priority from the node's PriorityLabel
+	// label or annotation (0 if unset), used by the priority strategy
+	Priority int `json:"priority"`
+	// Version is the node's Talos version, e.g. v1.11.0 (empty if unknown)
+	Version string `json:"version,omitempty"`
+	// Stage is the node's machine stage, e.g. booting or running (empty if unknown)
+	Stage string `json:"stage,omitempty"`
+	// Ready is true if the node reports its machine status as ready
+	Ready bool `json:"ready"`
+	// EtcdState is the state of the node's etcd service, e.g. Preparing (empty if unknown)
+	EtcdState string `json:"etcdState,omitempty"`
+	// Addresses lists all addresses of the node's up interfaces (empty if unknown)
+	Addresses []netip.Addr `json:"addresses,omitempty"`
+	// NodeID is the node's Talos identity, persisted across reboots but reset
+	// on wipe (empty if unknown)
+	NodeID string `json:"nodeID,omitempty"`
+	// ClusterID is the ID of the cluster the node is configured for (empty if unknown)
+	ClusterID string `json:"clusterID,omitempty"`
+	// ClusterName is the name of the cluster the node is configured for (empty if unknown)
+	ClusterName string `json:"clusterName,omitempty"`
+	// Source tells how the node was found
+	Source DiscoverySource `json:"source,omitempty"`
+}
+
+// DiscoverySource tells how a node was found.
+type DiscoverySource string
+
+// Discovery sources.
+const (
+	// SourceLocal is the local node
+	SourceLocal DiscoverySource = "local"
+	// SourceCache is a known peer from the peer cache
+	SourceCache DiscoverySource = "cache"
+	// SourceSeed is an address from the kernel's neighbor table
+	SourceSeed DiscoverySource = "seed"
+	// SourceScan is an address found by sweeping the scan ranges
+	SourceScan DiscoverySource = "scan"
+)
+
+// InMaintenance reports whether the node runs in maintenance mode.
+func (n DiscoveredNode) InMaintenance() bool {
+	return n.Stage == runtimeres.MachineStageMaintenance.String()
 }
 
 // ScanCIDRForTalosNodes scans a CIDR range for Talos nodes.
@@ -42,48 +93,13 @@ type DiscoveredNode struct {
 func ScanCIDRForTalosNodes(ctx context.Context, cidr netip.Prefix,
 	localIP netip.Addr, timeout time.Duration, concurrency int) ([]DiscoveredNode, error) {
 
-	var (
-		nodes   []DiscoveredNode
-		nodesMu sync.Mutex
-	)
-
-	ips := GenerateIPsInCIDR(cidr)
-
-	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(concurrency)
-
-	for _, ip := range ips {
-		// Skip local IP
-		if ip == localIP {
-			continue
-		}
-
-		ip := ip // capture for goroutine
-		g.Go(func() error {
-			node, err := probeTalosNode(ctx, ip, timeout)
-			if err != nil {
-				return nil // Not a Talos node or unreachable, skip silently
-			}
-
-			nodesMu.Lock()
-			nodes = append(nodes, *node)
-			nodesMu.Unlock()
-			return nil
-		})
-	}
-
-	if err := g.Wait(); err != nil {
-		return nil, err
-	}
-
-	return nodes, nil
+	return ScanRangesForTalosNodes(ctx, []ScanRange{{CIDR: cidr}}, []netip.Addr{localIP},
+		ScanOptions{Timeout: timeout, Concurrency: concurrency})
 }
 
 // probeTalosNode attempts to connect to a potential Talos node and retrieve its info.
+// Failures are returned as a *ProbeError.
 func probeTalosNode(ctx context.Context, ip netip.Addr, timeout time.Duration) (*DiscoveredNode, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
 	endpoint := fmt.Sprintf("%s:%d", ip.String(), TalosAPIPort)
 
 	// Create client with insecure TLS (required for discovery of unknown nodes)
@@ -96,16 +112,27 @@ func probeTalosNode(ctx context.Context, ip netip.Addr, timeout time.Duration) (
 		),
 	)
 	if err != nil {
-		return nil, err
+		return nil, newProbeError(err, false)
 	}
 	defer func() { _ = client.Close() }()
 
+	return probeClient(ctx, client, ip, timeout)
+}
+
+// probeClient retrieves the info of the Talos node at ip through client.
+// Failures are returned as a *ProbeError.
+func probeClient(ctx context.Context, client *talosclient.Client, ip netip.Addr,
+	timeout time.Duration) (*DiscoveredNode, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
 	nodeCtx := talosclient.WithNode(ctx, ip.String())
 
 	// Verify it's a Talos node by getting version
 	version, err := client.Version(nodeCtx)
 	if err != nil {
-		return nil, err
+		return nil, newProbeError(err, false)
 	}
 
 	// Get machine type to determine if control plane
@@ -113,21 +140,22 @@ func probeTalosNode(ctx context.Context, ip netip.Addr, timeout time.Duration) (
 		resource.NewMetadata(configres.NamespaceName, configres.MachineTypeType,
 			configres.MachineTypeID, resource.VersionUndefined))
 	if err != nil {
-		return nil, err
+		return nil, newProbeError(err, true)
 	}
 
-	var hostname string
+	var hostname, talosVersion string
 	if len(version.Messages) > 0 && version.Messages[0].Metadata != nil {
 		hostname = version.Messages[0].Metadata.Hostname
 	}
+	if len(version.Messages) > 0 && version.Messages[0].Version != nil {
+		talosVersion = version.Messages[0].Version.Tag
+	}
 
 	// Get boot time from MachineStatus resource
 	bootTime := time.Now()
 
-	machineStatus, err := safe.StateGet[*runtimeres.MachineStatus](nodeCtx, client.COSI,
-		resource.NewMetadata(runtimeres.NamespaceName, runtimeres.MachineStatusType,
-			runtimeres.MachineStatusID, resource.VersionUndefined))
-	if err == nil && machineStatus.TypedSpec().Stage != runtimeres.MachineStageUnknown {
+	stage, ready := getMachineStatus(nodeCtx, client)
+	if stage != "" && stage != runtimeres.MachineStageUnknown.String() {
 		// Use version info if available
 		if len(version.Messages) > 0 && version.Messages[0].Version != nil {
 			// Parse built time as a proxy for consistent ordering
@@ -141,26 +169,175 @@ func probeTalosNode(ctx context.Context, ip netip.Addr, timeout time.Duration) (
 		}
 	}
 
-	return &DiscoveredNode{
+	node := &DiscoveredNode{
 		IP:             ip,
 		IsControlPlane: mt.MachineType().String() == "controlplane",
 		CreationTime:   bootTime,
 		Hostname:       hostname,
-	}, nil
+		MachineUUID:    getMachineUUID(nodeCtx, client),
+		Priority:       probePriority(nodeCtx, client),
+		Version:        talosVersion,
+		Stage:          stage,
+		Ready:          ready,
+	}
+	node.EtcdState = getEtcdState(nodeCtx, client)
+	node.Addresses = getAddresses(nodeCtx, client)
+	node.NodeID = getNodeID(nodeCtx, client)
+	node.ClusterID, node.ClusterName = getClusterInfo(nodeCtx, client)
+
+	return node, nil
+}
+
+// getMachineStatus reads the node's machine stage and readiness from the
+// MachineStatus resource. Returns an empty stage if it is not readable.
+func getMachineStatus(ctx context.Context, client *talosclient.Client) (stage string, ready bool) {
+	machineStatus, err := safe.StateGet[*runtimeres.MachineStatus](ctx, client.COSI,
+		resource.NewMetadata(runtimeres.NamespaceName, runtimeres.MachineStatusType,
+			runtimeres.MachineStatusID, resource.VersionUndefined))
+	if err != nil {
+		return "", false
+	}
+
+	return machineStatus.TypedSpec().Stage.String(), machineStatus.TypedSpec().Status.Ready
+}
+
+// getEtcdState reads the state of the node's etcd service.
+// Returns an empty string if it is not readable.
+func getEtcdState(ctx context.Context, client *talosclient.Client) string {
+	services, err := client.ServiceInfo(ctx, "etcd")
+	if err != nil || len(services) == 0 || services[0].Service == nil {
+		return ""
+	}
+
+	return services[0].Service.State
+}
+
+// getMachineUUID reads the node's system UUID from the SystemInformation resource.
+// Returns an empty string if it is not readable.
+func getMachineUUID(ctx context.Context, client *talosclient.Client) string {
+	info, err := safe.StateGet[*hardwareres.SystemInformation](ctx, client.COSI,
+		resource.NewMetadata(hardwareres.NamespaceName, hardwareres.SystemInformationType,
+			hardwareres.SystemInformationID, resource.VersionUndefined))
+	if err != nil {
+		return ""
+	}
+
+	return info.TypedSpec().UUID
+}
+
+// getAddresses reads the addresses of the node's up interfaces from the
+// current NodeAddress resource. Returns nil if it is not readable.
+func getAddresses(ctx context.Context, client *talosclient.Client) []netip.Addr {
+	addresses, err := safe.StateGet[*networkres.NodeAddress](ctx, client.COSI,
+		resource.NewMetadata(networkres.NamespaceName, networkres.NodeAddressType,
+			networkres.NodeAddressCurrentID, resource.VersionUndefined))
+	if err != nil {
+		return nil
+	}
+
+	addrs := make([]netip.Addr, 0, len(addresses.TypedSpec().Addresses))
+	for _, prefix := range addresses.TypedSpec().Addresses {
+		addrs = append(addrs, prefix.Addr())
+	}
+
+	return addrs
+}
+
+// getNodeID reads the node's identity from the local Identity resource.
+// Returns an empty string if it is not readable.
+func getNodeID(ctx context.Context, client *talosclient.Client) string {
+	identity, err := safe.StateGet[*clusterres.Identity](ctx, client.COSI,
+		resource.NewMetadata(clusterres.NamespaceName, clusterres.IdentityType,
+			clusterres.LocalIdentity, resource.VersionUndefined))
+	if err != nil {
+		return ""
+	}
+
+	return identity.TypedSpec().NodeID
+}
+
+// getClusterInfo reads the ID and name of the cluster the node is configured
+// for from the Info resource. Returns empty strings if it is not readable.
+func getClusterInfo(ctx context.Context, client *talosclient.Client) (id, name string) {
+	info, err := safe.StateGet[*clusterres.Info](ctx, client.COSI,
+		resource.NewMetadata(clusterres.NamespaceName, clusterres.InfoType,
+			clusterres.InfoID, resource.VersionUndefined))
+	if err != nil {
+		return "", ""
+	}
+
+	return info.TypedSpec().ClusterID, info.TypedSpec().ClusterName
+}
+
+// probePriority reads the election priority from the node's label or
+// annotation specs. Returns 0 if neither is set or readable.
+func probePriority(ctx context.Context, client *talosclient.Client) int {
+	label, err := safe.StateGet[*k8sres.NodeLabelSpec](ctx, client.COSI,
+		resource.NewMetadata(k8sres.NamespaceName, k8sres.NodeLabelSpecType,
+			PriorityLabel, resource.VersionUndefined))
+	if err == nil {
+		return ParsePriority(map[string]string{PriorityLabel: label.TypedSpec().Value}, nil)
+	}
+
+	annotation, err := safe.StateGet[*k8sres.NodeAnnotationSpec](ctx, client.COSI,
+		resource.NewMetadata(k8sres.NamespaceName, k8sres.NodeAnnotationSpecType,
+			PriorityLabel, resource.VersionUndefined))
+	if err == nil {
+		return ParsePriority(nil, map[string]string{PriorityLabel: annotation.TypedSpec().Value})
+	}
+
+	return 0
+}
+
+// ParsePriority returns the election priority from node labels or annotations
+// (labels take precedence). Returns 0 if unset or not an integer.
+func ParsePriority(labels, annotations map[string]string) int {
+	for _, values := range []map[string]string{labels, annotations} {
+		if value, ok := values[PriorityLabel]; ok {
+			if priority, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+				return priority
+			}
+		}
+	}
+
+	return 0
 }
 
 // GetLocalNodeInfo retrieves information about the local node.
 // Uses gRPC Version() call and filesystem instead of COSI.
+// The client may be nil, in which case only the filesystem is used.
 func GetLocalNodeInfo(ctx context.Context, client *talosclient.Client,
 	localIP netip.Addr) (*DiscoveredNode, error) {
 
 	var hostname string
 	var bootTime time.Time
 
+	node := &DiscoveredNode{
+		IP:             localIP,
+		IsControlPlane: true, // We only call this on control plane nodes
+		Source:         SourceLocal,
+	}
+
 	// Try to get hostname from Version() gRPC call
-	version, err := client.Version(ctx)
-	if err == nil && len(version.Messages) > 0 && version.Messages[0].Metadata != nil {
-		hostname = version.Messages[0].Metadata.Hostname
+	if client != nil {
+		version, err := client.Version(ctx)
+		if err == nil && len(version.Messages) > 0 && version.Messages[0].Metadata != nil {
+			hostname = version.Messages[0].Metadata.Hostname
+		}
+		if err == nil && len(version.Messages) > 0 && version.Messages[0].Version != nil {
+			node.Version = version.Messages[0].Version.Tag
+		}
+
+		node.MachineUUID = getMachineUUID(ctx, client)
+		node.Stage, node.Ready = getMachineStatus(ctx, client)
+		node.EtcdState = getEtcdState(ctx, client)
+		node.Addresses = getAddresses(ctx, client)
+		node.NodeID = getNodeID(ctx, client)
+		node.ClusterID, node.ClusterName = getClusterInfo(ctx, client)
+	}
+
+	if len(node.Addresses) == 0 {
+		node.Addresses = []netip.Addr{localIP}
 	}
 
 	// Fallback: get hostname from /etc/hostname or os.Hostname()
@@ -175,12 +352,10 @@ func GetLocalNodeInfo(ctx context.Context, client *talosclient.Client,
 	// Get boot time from /proc/stat
 	bootTime = getBootTime()
 
-	return &DiscoveredNode{
-		IP:             localIP,
-		IsControlPlane: true, // We only call this on control plane nodes
-		CreationTime:   bootTime,
-		Hostname:       hostname,
-	}, nil
+	node.CreationTime = bootTime
+	node.Hostname = hostname
+
+	return node, nil
 }
 
 // getBootTime reads the system boot time from /proc/stat.
@@ -0,0 +1,48 @@
+package discovery
+
+import "testing"
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		expected    int
+	}{
+		{
+			name:     "unset",
+			expected: 0,
+		},
+		{
+			name:     "from label",
+			labels:   map[string]string{PriorityLabel: "10"},
+			expected: 10,
+		},
+		{
+			name:        "from annotation",
+			annotations: map[string]string{PriorityLabel: " 5 "},
+			expected:    5,
+		},
+		{
+			name:        "label takes precedence",
+			labels:      map[string]string{PriorityLabel: "10"},
+			annotations: map[string]string{PriorityLabel: "5"},
+			expected:    10,
+		},
+		{
+			name:        "invalid label falls back to annotation",
+			labels:      map[string]string{PriorityLabel: "high"},
+			annotations: map[string]string{PriorityLabel: "5"},
+			expected:    5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ParsePriority(tt.labels, tt.annotations)
+			if result != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, result)
+			}
+		})
+	}
+}
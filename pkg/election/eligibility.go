@@ -0,0 +1,134 @@
+package election
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/blang/semver/v4"
+
+	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
+)
+
+// Eligibility decides which control plane nodes may take part in an election.
+// The zero value accepts every control plane node.
+type Eligibility struct {
+	// Stages lists the accepted machine stages, e.g. booting and running (empty accepts all)
+	Stages []string
+	// RequireReady requires the node to report its machine status as ready
+	RequireReady bool
+	// MinVersion is the minimum Talos version, e.g. v1.10.0 (empty disables the check)
+	MinVersion string
+	// MatchVersion requires the same Talos version as the local node
+	MatchVersion bool
+	// EtcdStates lists the accepted etcd service states, e.g. Preparing (empty accepts all)
+	EtcdStates []string
+	// ExcludeMaintenance rejects nodes in maintenance mode
+	ExcludeMaintenance bool
+}
+
+// Rejection is a control plane node that is not eligible for election.
+type Rejection struct {
+	// Node is the rejected node
+	Node discovery.DiscoveredNode
+	// Reasons lists why the node was rejected
+	Reasons []string
+}
+
+// String renders the rejection for logs and the status.
+func (r Rejection) String() string {
+	name := r.Node.IP.String()
+	if r.Node.Hostname != "" {
+		name = fmt.Sprintf("%s (%s)", name, r.Node.Hostname)
+	}
+	return fmt.Sprintf("%s: %s", name, strings.Join(r.Reasons, ", "))
+}
+
+// Check returns the reasons why node is not eligible, or nil if it is.
+// localVersion is the local node's Talos version, used by MatchVersion.
+func (e Eligibility) Check(node discovery.DiscoveredNode, localVersion string) []string {
+	var reasons []string
+
+	if e.ExcludeMaintenance && node.InMaintenance() {
+		reasons = append(reasons, "in maintenance mode")
+	}
+
+	if len(e.Stages) > 0 && !slices.Contains(e.Stages, node.Stage) {
+		reasons = append(reasons, fmt.Sprintf("stage %s is not one of %v", unknownIfEmpty(node.Stage), e.Stages))
+	}
+
+	if e.RequireReady && !node.Ready {
+		reasons = append(reasons, "not ready")
+	}
+
+	if e.MinVersion != "" {
+		if reason := checkMinVersion(node.Version, e.MinVersion); reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+
+	if e.MatchVersion && node.Version != localVersion {
+		reasons = append(reasons, fmt.Sprintf("version %s does not match local version %s",
+			unknownIfEmpty(node.Version), unknownIfEmpty(localVersion)))
+	}
+
+	if len(e.EtcdStates) > 0 && !slices.Contains(e.EtcdStates, node.EtcdState) {
+		reasons = append(reasons, fmt.Sprintf("etcd state %s is not one of %v",
+			unknownIfEmpty(node.EtcdState), e.EtcdStates))
+	}
+
+	return reasons
+}
+
+// FilterEligible splits the control plane peers into eligible peers and
+// rejections. Worker peers are passed through unchanged, as they never take
+// part in elections.
+func (e Eligibility) FilterEligible(localNode discovery.DiscoveredNode,
+	peers []discovery.DiscoveredNode) ([]discovery.DiscoveredNode, []Rejection) {
+
+	eligible := make([]discovery.DiscoveredNode, 0, len(peers))
+	var rejected []Rejection
+
+	for _, peer := range peers {
+		if !peer.IsControlPlane {
+			eligible = append(eligible, peer)
+			continue
+		}
+
+		if reasons := e.Check(peer, localNode.Version); len(reasons) > 0 {
+			rejected = append(rejected, Rejection{Node: peer, Reasons: reasons})
+			continue
+		}
+
+		eligible = append(eligible, peer)
+	}
+
+	return eligible, rejected
+}
+
+// checkMinVersion returns why version does not meet minVersion, or "".
+func checkMinVersion(version, minVersion string) string {
+	minimum, err := semver.ParseTolerant(minVersion)
+	if err != nil {
+		return fmt.Sprintf("invalid minimum version %s", minVersion)
+	}
+
+	v, err := semver.ParseTolerant(version)
+	if err != nil {
+		return fmt.Sprintf("version %s is not a valid version", unknownIfEmpty(version))
+	}
+
+	if v.LT(minimum) {
+		return fmt.Sprintf("version %s is older than %s", version, minVersion)
+	}
+
+	return ""
+}
+
+// unknownIfEmpty returns "unknown" for empty values.
+func unknownIfEmpty(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
@@ -0,0 +1,84 @@
+package election
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
+)
+
+// eligibleNode returns a control plane node that passes all default filters.
+func eligibleNode() discovery.DiscoveredNode {
+	return discovery.DiscoveredNode{
+		IP:             netip.MustParseAddr("10.0.0.2"),
+		IsControlPlane: true,
+		Version:        "v1.11.0",
+		Stage:          "booting",
+		EtcdState:      "Preparing",
+	}
+}
+
+func TestEligibility_Check(t *testing.T) {
+	eligibility := Eligibility{
+		Stages:             []string{"booting", "running"},
+		MinVersion:         "v1.10.0",
+		EtcdStates:         []string{"Waiting", "Preparing"},
+		ExcludeMaintenance: true,
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(*discovery.DiscoveredNode)
+		eligible bool
+	}{
+		{name: "eligible", modify: func(n *discovery.DiscoveredNode) {}, eligible: true},
+		{name: "maintenance mode", modify: func(n *discovery.DiscoveredNode) { n.Stage = "maintenance" }},
+		{name: "unknown stage", modify: func(n *discovery.DiscoveredNode) { n.Stage = "" }},
+		{name: "old version", modify: func(n *discovery.DiscoveredNode) { n.Version = "v1.9.5" }},
+		{name: "unknown version", modify: func(n *discovery.DiscoveredNode) { n.Version = "" }},
+		{name: "etcd running", modify: func(n *discovery.DiscoveredNode) { n.EtcdState = "Running" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := eligibleNode()
+			tt.modify(&node)
+
+			reasons := eligibility.Check(node, "v1.11.0")
+			if (len(reasons) == 0) != tt.eligible {
+				t.Errorf("expected eligible %v, got reasons %v", tt.eligible, reasons)
+			}
+		})
+	}
+}
+
+func TestEligibility_ZeroValueAcceptsAll(t *testing.T) {
+	if reasons := (Eligibility{}).Check(discovery.DiscoveredNode{IsControlPlane: true}, ""); len(reasons) > 0 {
+		t.Errorf("expected no reasons, got %v", reasons)
+	}
+}
+
+func TestEligibility_FilterEligible(t *testing.T) {
+	eligibility := Eligibility{MatchVersion: true}
+
+	localNode := eligibleNode()
+	localNode.IP = netip.MustParseAddr("10.0.0.1")
+
+	mismatch := eligibleNode()
+	mismatch.IP = netip.MustParseAddr("10.0.0.3")
+	mismatch.Version = "v1.10.4"
+
+	worker := discovery.DiscoveredNode{IP: netip.MustParseAddr("10.0.0.4")}
+
+	eligible, rejected := eligibility.FilterEligible(localNode, []discovery.DiscoveredNode{eligibleNode(), mismatch, worker})
+
+	if len(eligible) != 2 {
+		t.Errorf("expected the matching peer and the worker to pass, got %v", eligible)
+	}
+	if len(rejected) != 1 || rejected[0].Node.IP != mismatch.IP {
+		t.Fatalf("expected %s to be rejected, got %v", mismatch.IP, rejected)
+	}
+	if want := "10.0.0.3: version v1.10.4 does not match local version v1.11.0"; rejected[0].String() != want {
+		t.Errorf("expected %q, got %q", want, rejected[0].String())
+	}
+}
@@ -0,0 +1,105 @@
+package election
+
+import (
+	"net/netip"
+)
+
+// Token is a fencing token: an election epoch and the leader that claimed it.
+// Epochs increase monotonically; only a node that elected itself claims a new
+// epoch, all other nodes adopt the latest token they observe from peers.
+type Token struct {
+	// Epoch is the election epoch
+	Epoch uint64
+	// Leader is the node that claimed the epoch
+	Leader netip.Addr
+}
+
+// IsZero reports whether no epoch was claimed yet.
+func (t Token) IsZero() bool {
+	return t.Epoch == 0
+}
+
+// Supersedes reports whether t supersedes other: it has a higher epoch, or
+// the same epoch claimed by a node with a lower IP (concurrent claims).
+func (t Token) Supersedes(other Token) bool {
+	if t.Epoch != other.Epoch {
+		return t.Epoch > other.Epoch
+	}
+	return t.Leader.IsValid() && other.Leader.IsValid() && t.Leader.Less(other.Leader)
+}
+
+// Latest returns the token that supersedes all others, or the zero token.
+func Latest(tokens ...Token) Token {
+	var latest Token
+	for _, token := range tokens {
+		if token.Supersedes(latest) {
+			latest = token
+		}
+	}
+	return latest
+}
+
+// Claim returns a new token for leader that supersedes all observed tokens.
+func Claim(leader netip.Addr, observed ...Token) Token {
+	return Token{Epoch: Latest(observed...).Epoch + 1, Leader: leader}
+}
+
+// EpochAction is what a node does with its token after an election.
+type EpochAction int
+
+// Epoch actions.
+const (
+	// EpochAdopt means a follower adopts the latest observed token
+	EpochAdopt EpochAction = iota
+	// EpochKeep means the leader already holds the latest token
+	EpochKeep
+	// EpochClaim means the leader claims a new epoch
+	EpochClaim
+	// EpochFenced means the leader's claim was superseded by a newer epoch: it
+	// must step down and no longer lead
+	EpochFenced
+	// EpochYield means the leader lost a concurrent claim of the same epoch to
+	// a lower IP: it adopts the winner's token and steps down for this round
+	EpochYield
+)
+
+// UpdateEpoch returns the token local holds after an election and the action
+// that led to it. A leader claims a new epoch unless it already holds the
+// latest one; followers adopt the latest token observed from peers. A leader
+// whose claim was superseded adopts the latest token and is fenced, or yields
+// if it lost a concurrent claim of the same epoch.
+func UpdateEpoch(current Token, local netip.Addr, isLeader bool, observed ...Token) (Token, EpochAction) {
+	latest := Latest(append(observed, current)...)
+
+	switch {
+	case !isLeader:
+		return latest, EpochAdopt
+	case current.Leader == local && latest != current:
+		return latest, supersededBy(current, latest)
+	case latest.Leader == local:
+		return latest, EpochKeep
+	default:
+		return Claim(local, latest), EpochClaim
+	}
+}
+
+// Fence checks that token is still the latest of the peer tokens before its
+// holder bootstraps. If so, it returns token and EpochKeep. Otherwise it
+// returns the latest token and EpochFenced or EpochYield, as in UpdateEpoch.
+func Fence(token Token, peers ...Token) (Token, EpochAction) {
+	latest := Latest(peers...)
+	if !latest.Supersedes(token) {
+		return token, EpochKeep
+	}
+	return latest, supersededBy(token, latest)
+}
+
+// supersededBy returns the action of a leader whose token was superseded by
+// latest: it yields to a concurrent claim of the same epoch and is fenced by
+// a newer epoch.
+func supersededBy(token, latest Token) EpochAction {
+	if latest.Epoch == token.Epoch {
+		return EpochYield
+	}
+	return EpochFenced
+}
@@ -0,0 +1,157 @@
+package election
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestToken_Supersedes(t *testing.T) {
+	a := netip.MustParseAddr("10.0.0.1")
+	b := netip.MustParseAddr("10.0.0.2")
+
+	tests := []struct {
+		name     string
+		token    Token
+		other    Token
+		expected bool
+	}{
+		{name: "higher epoch", token: Token{Epoch: 2, Leader: b}, other: Token{Epoch: 1, Leader: a}, expected: true},
+		{name: "lower epoch", token: Token{Epoch: 1, Leader: a}, other: Token{Epoch: 2, Leader: b}, expected: false},
+		{name: "concurrent claim by lower IP", token: Token{Epoch: 3, Leader: a}, other: Token{Epoch: 3, Leader: b}, expected: true},
+		{name: "concurrent claim by higher IP", token: Token{Epoch: 3, Leader: b}, other: Token{Epoch: 3, Leader: a}, expected: false},
+		{name: "same token", token: Token{Epoch: 3, Leader: a}, other: Token{Epoch: 3, Leader: a}, expected: false},
+		{name: "any claim supersedes none", token: Token{Epoch: 1, Leader: b}, other: Token{}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.Supersedes(tt.other); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFence_StaleLeaderAfterPartition(t *testing.T) {
+	stale := netip.MustParseAddr("10.0.0.1")
+	next := netip.MustParseAddr("10.0.0.2")
+
+	// The stale leader claimed epoch 3, then the others failed over to a new leader
+	staleToken := Claim(stale, Token{Epoch: 2, Leader: stale})
+	newToken := Claim(next, staleToken)
+
+	if newToken.Epoch != 4 {
+		t.Fatalf("expected epoch 4, got %d", newToken.Epoch)
+	}
+	if latest, action := Fence(staleToken, newToken); action != EpochFenced || latest != newToken {
+		t.Errorf("expected stale leader to be fenced by %v, got %v (%v)", newToken, latest, action)
+	}
+	if _, action := Fence(newToken, staleToken); action != EpochKeep {
+		t.Errorf("expected new leader not to be fenced, got %v", action)
+	}
+	if _, action := Fence(newToken); action != EpochKeep {
+		t.Errorf("expected leader without peers not to be fenced, got %v", action)
+	}
+
+	// A concurrent claim of the same epoch by a lower IP only makes the leader yield
+	rival := Token{Epoch: newToken.Epoch, Leader: stale}
+	if latest, action := Fence(newToken, rival); action != EpochYield || latest != rival {
+		t.Errorf("expected new leader to yield to %v, got %v (%v)", rival, latest, action)
+	}
+}
+
+func TestUpdateEpoch(t *testing.T) {
+	a := netip.MustParseAddr("10.0.0.1")
+	b := netip.MustParseAddr("10.0.0.2")
+	c := netip.MustParseAddr("10.0.0.3")
+
+	tests := []struct {
+		name       string
+		current    Token
+		local      netip.Addr
+		isLeader   bool
+		observed   []Token
+		wantToken  Token
+		wantAction EpochAction
+	}{
+		{
+			name:       "leader claims the first epoch",
+			local:      a,
+			isLeader:   true,
+			wantToken:  Token{Epoch: 1, Leader: a},
+			wantAction: EpochClaim,
+		},
+		{
+			name:       "leader claims over a previous leader",
+			current:    Token{Epoch: 2, Leader: c},
+			local:      b,
+			isLeader:   true,
+			observed:   []Token{{Epoch: 2, Leader: c}, {Epoch: 1, Leader: a}},
+			wantToken:  Token{Epoch: 3, Leader: b},
+			wantAction: EpochClaim,
+		},
+		{
+			name:       "leader keeps its token",
+			current:    Token{Epoch: 3, Leader: b},
+			local:      b,
+			isLeader:   true,
+			observed:   []Token{{Epoch: 3, Leader: b}, {Epoch: 2, Leader: c}},
+			wantToken:  Token{Epoch: 3, Leader: b},
+			wantAction: EpochKeep,
+		},
+		{
+			name:       "follower adopts a higher token",
+			current:    Token{Epoch: 1, Leader: a},
+			local:      c,
+			observed:   []Token{{Epoch: 4, Leader: b}, {Epoch: 2, Leader: a}},
+			wantToken:  Token{Epoch: 4, Leader: b},
+			wantAction: EpochAdopt,
+		},
+		{
+			name:       "follower keeps its token without peers",
+			current:    Token{Epoch: 2, Leader: a},
+			local:      c,
+			wantToken:  Token{Epoch: 2, Leader: a},
+			wantAction: EpochAdopt,
+		},
+		{
+			name:       "leader steps down on a superseding token",
+			current:    Token{Epoch: 3, Leader: a},
+			local:      a,
+			isLeader:   true,
+			observed:   []Token{{Epoch: 4, Leader: b}},
+			wantToken:  Token{Epoch: 4, Leader: b},
+			wantAction: EpochFenced,
+		},
+		{
+			name:       "same-epoch race is won by the lower IP",
+			current:    Token{Epoch: 3, Leader: a},
+			local:      a,
+			isLeader:   true,
+			observed:   []Token{{Epoch: 3, Leader: b}},
+			wantToken:  Token{Epoch: 3, Leader: a},
+			wantAction: EpochKeep,
+		},
+		{
+			name:       "same-epoch race is lost by the higher IP",
+			current:    Token{Epoch: 3, Leader: b},
+			local:      b,
+			isLeader:   true,
+			observed:   []Token{{Epoch: 3, Leader: a}},
+			wantToken:  Token{Epoch: 3, Leader: a},
+			wantAction: EpochYield,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, action := UpdateEpoch(tt.current, tt.local, tt.isLeader, tt.observed...)
+			if token != tt.wantToken {
+				t.Errorf("expected token %v, got %v", tt.wantToken, token)
+			}
+			if action != tt.wantAction {
+				t.Errorf("expected action %v, got %v", tt.wantAction, action)
+			}
+		})
+	}
+}
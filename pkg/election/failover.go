@@ -0,0 +1,147 @@
+package election
+
+import (
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
+)
+
+// Failover demotes leaders that stall. It tracks how long the same leader has
+// been elected without the cluster being bootstrapped; once that exceeds the
+// stall timeout, the leader is demoted and excluded from later elections so
+// the next candidate takes over.
+//
+// Stall demotion is local to each node. Followers observe the same leader and
+// reach the deadline at roughly the same time, so they agree on the next
+// leader. A stalled local leader demotes itself the same way and stops
+// bootstrapping. A leader that demotes itself, e.g. after it was fenced,
+// publishes this in its status; peers adopt the demotion (see Adopt).
+type Failover struct {
+	timeout time.Duration
+	now     func() time.Time
+
+	leader  netip.Addr
+	since   time.Time
+	demoted []netip.Addr
+}
+
+// NewFailover creates a failover tracker. A zero timeout disables failover.
+func NewFailover(timeout time.Duration) *Failover {
+	return &Failover{
+		timeout: timeout,
+		now:     time.Now,
+	}
+}
+
+// Elect performs leader election with the given strategy, excluding demoted
+// leaders. The local node is never elected if it was demoted itself, unless
+// it is the only candidate left.
+func (f *Failover) Elect(strategy Strategy, localNode discovery.DiscoveredNode,
+	peers []discovery.DiscoveredNode) *ElectionResult {
+
+	peers = slices.DeleteFunc(slices.Clone(peers), func(peer discovery.DiscoveredNode) bool {
+		return f.IsDemoted(peer.IP)
+	})
+
+	result := ElectLeaderWithStrategy(strategy, localNode, peers)
+	if !result.IsLeader || !f.IsDemoted(localNode.IP) || len(result.Candidates) < 2 {
+		return result
+	}
+
+	// The local node stepped down: the next candidate leads
+	result.Leader = &result.Candidates[1]
+	result.IsLeader = false
+
+	return result
+}
+
+// Observe records the leader elected in the current round and reports whether
+// it has now stalled past the timeout, in which case it is demoted.
+func (f *Failover) Observe(leader discovery.DiscoveredNode) bool {
+	if f.timeout <= 0 {
+		return false
+	}
+
+	now := f.now()
+	if leader.IP != f.leader {
+		f.leader = leader.IP
+		f.since = now
+		return false
+	}
+
+	if now.Sub(f.since) < f.timeout {
+		return false
+	}
+
+	f.demoted = append(f.demoted, leader.IP)
+	f.leader = netip.Addr{}
+
+	return true
+}
+
+// Demote demotes the node with the given IP, e.g. a local leader that was fenced.
+func (f *Failover) Demote(ip netip.Addr) {
+	if !f.IsDemoted(ip) {
+		f.demoted = append(f.demoted, ip)
+	}
+	if f.leader == ip {
+		f.leader = netip.Addr{}
+	}
+}
+
+// Adopt demotes peer if the demoted leaders it published include itself, i.e.
+// it stepped down, so peers stop electing a leader that will not bootstrap.
+// Demotions of other nodes are not adopted; each node decides those itself.
+// Reports whether peer was newly demoted.
+func (f *Failover) Adopt(peer netip.Addr, peerDemoted []netip.Addr) bool {
+	if f.IsDemoted(peer) || !slices.Contains(peerDemoted, peer) {
+		return false
+	}
+
+	f.Demote(peer)
+
+	return true
+}
+
+// Peers returns the IPs of the candidates other than localIP plus the demoted
+// leaders for which present reports true. Their state is exchanged every
+// round and they are checked for etcd before bootstrapping. A demoted leader
+// that is gone, e.g. a crashed leader the last full sweep no longer found,
+// is left out: it cannot answer, and would block every later leader.
+func (f *Failover) Peers(candidates []discovery.DiscoveredNode, localIP netip.Addr,
+	present func(netip.Addr) bool) []netip.Addr {
+
+	var peers []netip.Addr
+	for _, ip := range f.demoted {
+		if present(ip) {
+			peers = append(peers, ip)
+		}
+	}
+	for _, candidate := range candidates {
+		if !slices.Contains(peers, candidate.IP) {
+			peers = append(peers, candidate.IP)
+		}
+	}
+
+	return slices.DeleteFunc(peers, func(ip netip.Addr) bool { return ip == localIP })
+}
+
+// IsDemoted reports whether the node with the given IP was demoted.
+func (f *Failover) IsDemoted(ip netip.Addr) bool {
+	return slices.Contains(f.demoted, ip)
+}
+
+// Demoted returns the demoted leaders in the order they were demoted.
+func (f *Failover) Demoted() []netip.Addr {
+	return slices.Clone(f.demoted)
+}
+
+// StalledFor returns how long the current leader has been elected.
+func (f *Failover) StalledFor() time.Duration {
+	if !f.leader.IsValid() {
+		return 0
+	}
+	return f.now().Sub(f.since)
+}
@@ -0,0 +1,165 @@
+package election
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
+)
+
+// newTestFailover returns a failover tracker with a controllable clock.
+func newTestFailover(timeout time.Duration) (*Failover, *time.Time) {
+	now := time.Now()
+	f := NewFailover(timeout)
+	f.now = func() time.Time { return now }
+
+	return f, &now
+}
+
+func TestFailover_DemotesStalledLeader(t *testing.T) {
+	f, now := newTestFailover(10 * time.Minute)
+
+	localNode, peers := strategyTestNodes()
+	localNode.CreationTime = localNode.CreationTime.Add(time.Hour) // make 192.168.1.10 the oldest
+
+	result := f.Elect(BootTimeStrategy{}, localNode, peers)
+	if result.Leader.IP.String() != "192.168.1.10" {
+		t.Fatalf("expected leader 192.168.1.10, got %s", result.Leader.IP)
+	}
+	if f.Observe(*result.Leader) {
+		t.Fatal("leader demoted on first observation")
+	}
+
+	*now = now.Add(5 * time.Minute)
+	if f.Observe(*result.Leader) {
+		t.Fatal("leader demoted before the stall timeout")
+	}
+
+	*now = now.Add(5 * time.Minute)
+	if !f.Observe(*result.Leader) {
+		t.Fatal("expected leader to be demoted after the stall timeout")
+	}
+
+	result = f.Elect(BootTimeStrategy{}, localNode, peers)
+	if result.Leader.IP.String() != "192.168.1.11" {
+		t.Errorf("expected next leader 192.168.1.11, got %s", result.Leader.IP)
+	}
+	for _, candidate := range result.Candidates {
+		if candidate.IP.String() == "192.168.1.10" {
+			t.Error("demoted leader is still a candidate")
+		}
+	}
+}
+
+func TestFailover_LeaderChangeResetsDeadline(t *testing.T) {
+	f, now := newTestFailover(10 * time.Minute)
+
+	a := discovery.DiscoveredNode{IP: netip.MustParseAddr("10.0.0.1")}
+	b := discovery.DiscoveredNode{IP: netip.MustParseAddr("10.0.0.2")}
+
+	f.Observe(a)
+	*now = now.Add(8 * time.Minute)
+	f.Observe(b)
+	*now = now.Add(8 * time.Minute)
+
+	if f.Observe(b) {
+		t.Error("leader demoted although it was only elected for 8 minutes")
+	}
+}
+
+func TestFailover_LocalLeaderStepsDown(t *testing.T) {
+	f, now := newTestFailover(time.Minute)
+
+	localNode, peers := strategyTestNodes() // local node is the oldest
+
+	result := f.Elect(BootTimeStrategy{}, localNode, peers)
+	if !result.IsLeader {
+		t.Fatal("expected local node to be leader")
+	}
+	f.Observe(*result.Leader)
+	*now = now.Add(time.Minute)
+	if !f.Observe(*result.Leader) {
+		t.Fatal("expected local leader to be demoted")
+	}
+
+	result = f.Elect(BootTimeStrategy{}, localNode, peers)
+	if result.IsLeader {
+		t.Error("demoted local node must not lead")
+	}
+	if result.Leader.IP.String() != "192.168.1.10" {
+		t.Errorf("expected next leader 192.168.1.10, got %s", result.Leader.IP)
+	}
+}
+
+func TestFailover_Disabled(t *testing.T) {
+	f, now := newTestFailover(0)
+
+	leader := discovery.DiscoveredNode{IP: netip.MustParseAddr("10.0.0.1")}
+	f.Observe(leader)
+	*now = now.Add(24 * time.Hour)
+
+	if f.Observe(leader) {
+		t.Error("leader demoted with failover disabled")
+	}
+}
+
+func TestFailover_AdoptsPeerStepDown(t *testing.T) {
+	f, _ := newTestFailover(0)
+
+	localNode, peers := strategyTestNodes()
+	localNode.CreationTime = localNode.CreationTime.Add(time.Hour) // make 192.168.1.10 the oldest
+	leader := netip.MustParseAddr("192.168.1.10")
+	other := netip.MustParseAddr("192.168.1.11")
+
+	if f.Adopt(leader, []netip.Addr{other}) {
+		t.Error("adopted a demotion of another node")
+	}
+	if f.Adopt(leader, nil) {
+		t.Error("adopted a demotion the peer did not publish")
+	}
+
+	result := f.Elect(BootTimeStrategy{}, localNode, peers)
+	if result.Leader.IP != leader {
+		t.Fatalf("expected leader %s, got %s", leader, result.Leader.IP)
+	}
+
+	// The leader was fenced and published its own demotion
+	if !f.Adopt(leader, []netip.Addr{leader}) {
+		t.Fatal("expected the leader's step-down to be adopted")
+	}
+	if f.Adopt(leader, []netip.Addr{leader}) {
+		t.Error("adopted the same step-down twice")
+	}
+
+	result = f.Elect(BootTimeStrategy{}, localNode, peers)
+	if result.Leader.IP != other {
+		t.Errorf("expected next leader %s, got %s", other, result.Leader.IP)
+	}
+}
+
+func TestFailover_PeersSkipsUnreachableDemotedLeader(t *testing.T) {
+	f, _ := newTestFailover(10 * time.Minute)
+
+	local := netip.MustParseAddr("10.0.0.1")
+	crashed := netip.MustParseAddr("10.0.0.2")
+	follower := netip.MustParseAddr("10.0.0.3")
+	candidates := []discovery.DiscoveredNode{{IP: local}, {IP: follower}}
+
+	f.Demote(crashed)
+
+	reachable := func(netip.Addr) bool { return true }
+	if peers := f.Peers(candidates, local, reachable); !slices.Equal(peers, []netip.Addr{crashed, follower}) {
+		t.Errorf("expected the demoted leader to be checked while it is found, got %v", peers)
+	}
+
+	// The crashed leader is no longer found by a full sweep
+	gone := func(ip netip.Addr) bool { return ip != crashed }
+	if peers := f.Peers(candidates, local, gone); !slices.Equal(peers, []netip.Addr{follower}) {
+		t.Errorf("expected only %s once the demoted leader is gone, got %v", follower, peers)
+	}
+	if !f.IsDemoted(crashed) {
+		t.Error("expected the leader to stay demoted")
+	}
+}
@@ -1,7 +1,11 @@
 package election
 
 import (
+	"fmt"
+	"net/netip"
+	"slices"
 	"sort"
+	"strings"
 
 	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
 )
@@ -14,6 +18,8 @@ type ElectionResult struct {
 	IsLeader bool
 	// Candidates is the list of all participating control plane nodes
 	Candidates []discovery.DiscoveredNode
+	// Strategy is the name of the strategy used to elect the leader
+	Strategy string
 }
 
 // ElectLeader performs deterministic leader election among control plane nodes.
@@ -25,6 +31,14 @@ type ElectionResult struct {
 func ElectLeader(localNode discovery.DiscoveredNode,
 	peers []discovery.DiscoveredNode) *ElectionResult {
 
+	return ElectLeaderWithStrategy(BootTimeStrategy{}, localNode, peers)
+}
+
+// ElectLeaderWithStrategy performs deterministic leader election among
+// control plane nodes, ordering candidates with the given strategy.
+func ElectLeaderWithStrategy(strategy Strategy, localNode discovery.DiscoveredNode,
+	peers []discovery.DiscoveredNode) *ElectionResult {
+
 	// Collect all control plane candidates
 	candidates := make([]discovery.DiscoveredNode, 0, len(peers)+1)
 	candidates = append(candidates, localNode)
@@ -35,14 +49,8 @@ func ElectLeader(localNode discovery.DiscoveredNode,
 		}
 	}
 
-	// Sort by creation time, then by IP for deterministic tie-breaking
 	sort.Slice(candidates, func(i, j int) bool {
-		if candidates[i].CreationTime.Equal(candidates[j].CreationTime) {
-			// Tie-break by IP address (lowest wins)
-			return candidates[i].IP.Less(candidates[j].IP)
-		}
-		// Oldest node (earliest creation time) wins
-		return candidates[i].CreationTime.Before(candidates[j].CreationTime)
+		return strategy.Less(candidates[i], candidates[j])
 	})
 
 	leader := &candidates[0]
@@ -51,6 +59,7 @@ func ElectLeader(localNode discovery.DiscoveredNode,
 		Leader:     leader,
 		IsLeader:   leader.IP == localNode.IP,
 		Candidates: candidates,
+		Strategy:   strategy.Name(),
 	}
 }
 
@@ -64,3 +73,105 @@ func QuorumReached(candidates []discovery.DiscoveredNode, minNodes int) bool {
 	}
 	return count >= minNodes
 }
+
+// MemberQuorum is the outcome of an expected-member quorum check.
+type MemberQuorum struct {
+	// Present lists the expected members found among the candidates
+	Present []string
+	// Missing lists the expected members not found among the candidates
+	Missing []string
+	// Required is the number of expected members that must be present
+	Required int
+	// Reached is true if at least Required expected members are present
+	Reached bool
+}
+
+// ExpectedMembersQuorum checks the control plane candidates against an expected
+// set of node identities (hostnames, IPs or machine UUIDs). required is the
+// number of expected members that must be present; 0 requires all of them.
+func ExpectedMembersQuorum(candidates []discovery.DiscoveredNode, expected []string,
+	required int) *MemberQuorum {
+
+	if required <= 0 || required > len(expected) {
+		required = len(expected)
+	}
+
+	quorum := &MemberQuorum{Required: required}
+
+	for _, member := range expected {
+		if slices.ContainsFunc(candidates, func(c discovery.DiscoveredNode) bool {
+			return c.IsControlPlane && MatchesIdentity(c, member)
+		}) {
+			quorum.Present = append(quorum.Present, member)
+		} else {
+			quorum.Missing = append(quorum.Missing, member)
+		}
+	}
+
+	quorum.Reached = len(quorum.Present) >= required
+
+	return quorum
+}
+
+// QuorumRule is the quorum required before an election may lead to a
+// bootstrap: an expected member set if Members is set, else a number of
+// control plane nodes.
+type QuorumRule struct {
+	// Nodes is the number of control plane nodes required if Members is empty
+	Nodes int
+	// Members are the expected members' hostnames, IPs or machine UUIDs
+	Members []string
+	// MembersRequired is the number of expected members that must be present (0 requires all)
+	MembersRequired int
+}
+
+// Required returns the number of control plane nodes or expected members
+// that must be present.
+func (r QuorumRule) Required() int {
+	if len(r.Members) == 0 {
+		return r.Nodes
+	}
+	if r.MembersRequired <= 0 || r.MembersRequired > len(r.Members) {
+		return len(r.Members)
+	}
+	return r.MembersRequired
+}
+
+// Check checks the rule against the candidates. With a node count, Present
+// and Missing are empty.
+func (r QuorumRule) Check(candidates []discovery.DiscoveredNode) *MemberQuorum {
+	if len(r.Members) > 0 {
+		return ExpectedMembersQuorum(candidates, r.Members, r.MembersRequired)
+	}
+
+	return &MemberQuorum{Required: r.Nodes, Reached: QuorumReached(candidates, r.Nodes)}
+}
+
+// String describes the rule for logs and the audit log.
+func (r QuorumRule) String() string {
+	if len(r.Members) == 0 {
+		return fmt.Sprintf("%d control plane nodes", r.Nodes)
+	}
+	return fmt.Sprintf("%d of expected members %s", r.Required(), strings.Join(r.Members, ","))
+}
+
+// MatchesIdentity reports whether a node is identified by the given hostname,
+// IP address or machine UUID (UUIDs are compared case-insensitively). Any of
+// the node's addresses identifies it, not only the one it was found on.
+func MatchesIdentity(node discovery.DiscoveredNode, identity string) bool {
+	ip, err := netip.ParseAddr(identity)
+	isIP := err == nil
+
+	switch {
+	case identity == "":
+		return false
+	case node.Hostname == identity:
+		return true
+	case isIP && (node.IP == ip || slices.Contains(node.Addresses, ip)):
+		return true
+	case node.MachineUUID != "" && strings.EqualFold(node.MachineUUID, identity):
+		return true
+	default:
+		return false
+	}
+}
@@ -2,6 +2,7 @@ package election
 
 import (
 	"net/netip"
+	"slices"
 	"testing"
 	"time"
 
@@ -249,3 +250,123 @@ func TestQuorumReached(t *testing.T) {
 		})
 	}
 }
+
+func TestExpectedMembersQuorum(t *testing.T) {
+	candidates := []discovery.DiscoveredNode{
+		{IP: netip.MustParseAddr("10.0.0.10"), IsControlPlane: true, Hostname: "cp-1"},
+		{IP: netip.MustParseAddr("10.0.0.11"), IsControlPlane: true, MachineUUID: "4C4C4544-0042"},
+		{IP: netip.MustParseAddr("10.0.0.20"), IsControlPlane: false, Hostname: "worker-1"},
+		{IP: netip.MustParseAddr("10.0.0.12"), IsControlPlane: true,
+			Addresses: []netip.Addr{netip.MustParseAddr("10.0.0.12"), netip.MustParseAddr("192.168.1.12")}},
+	}
+
+	tests := []struct {
+		name        string
+		expected    []string
+		required    int
+		wantReached bool
+		wantMissing []string
+	}{
+		{
+			name:        "all present by hostname, IP and UUID",
+			expected:    []string{"cp-1", "10.0.0.11", "4c4c4544-0042"},
+			wantReached: true,
+		},
+		{
+			name:        "present by another address",
+			expected:    []string{"cp-1", "192.168.1.12"},
+			wantReached: true,
+		},
+		{
+			name:        "all required but one missing",
+			expected:    []string{"cp-1", "10.0.0.11", "cp-3"},
+			wantReached: false,
+			wantMissing: []string{"cp-3"},
+		},
+		{
+			name:        "majority required",
+			expected:    []string{"cp-1", "10.0.0.11", "cp-3"},
+			required:    2,
+			wantReached: true,
+			wantMissing: []string{"cp-3"},
+		},
+		{
+			name:        "workers don't count",
+			expected:    []string{"cp-1", "worker-1"},
+			wantReached: false,
+			wantMissing: []string{"worker-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quorum := ExpectedMembersQuorum(candidates, tt.expected, tt.required)
+			if quorum.Reached != tt.wantReached {
+				t.Errorf("expected reached %v, got %v", tt.wantReached, quorum.Reached)
+			}
+			if !slices.Equal(quorum.Missing, tt.wantMissing) {
+				t.Errorf("expected missing %v, got %v", tt.wantMissing, quorum.Missing)
+			}
+		})
+	}
+}
+
+func TestQuorumRule(t *testing.T) {
+	candidates := []discovery.DiscoveredNode{
+		{IP: netip.MustParseAddr("10.0.0.10"), IsControlPlane: true, Hostname: "cp-1"},
+		{IP: netip.MustParseAddr("10.0.0.11"), IsControlPlane: true, Hostname: "cp-2"},
+	}
+
+	tests := []struct {
+		name         string
+		rule         QuorumRule
+		wantRequired int
+		wantReached  bool
+		wantString   string
+	}{
+		{
+			name:         "node count reached",
+			rule:         QuorumRule{Nodes: 2},
+			wantRequired: 2,
+			wantReached:  true,
+			wantString:   "2 control plane nodes",
+		},
+		{
+			name:         "node count not reached",
+			rule:         QuorumRule{Nodes: 3},
+			wantRequired: 3,
+			wantString:   "3 control plane nodes",
+		},
+		{
+			name:         "expected members take precedence over the node count",
+			rule:         QuorumRule{Nodes: 1, Members: []string{"cp-1", "cp-2", "cp-3"}},
+			wantRequired: 3,
+			wantString:   "3 of expected members cp-1,cp-2,cp-3",
+		},
+		{
+			name:         "majority of expected members",
+			rule:         QuorumRule{Nodes: 3, Members: []string{"cp-1", "cp-2", "cp-3"}, MembersRequired: 2},
+			wantRequired: 2,
+			wantReached:  true,
+			wantString:   "2 of expected members cp-1,cp-2,cp-3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Required(); got != tt.wantRequired {
+				t.Errorf("expected required %d, got %d", tt.wantRequired, got)
+			}
+			quorum := tt.rule.Check(candidates)
+			if quorum.Required != tt.wantRequired {
+				t.Errorf("expected checked required %d, got %d", tt.wantRequired, quorum.Required)
+			}
+			if quorum.Reached != tt.wantReached {
+				t.Errorf("expected reached %v, got %v", tt.wantReached, quorum.Reached)
+			}
+			if got := tt.rule.String(); got != tt.wantString {
+				t.Errorf("expected %q, got %q", tt.wantString, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package election
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
+)
+
+// Built-in strategy names.
+const (
+	StrategyBootTime = "boot-time"
+	StrategyLowestIP = "lowest-ip"
+	StrategyHostname = "hostname"
+	StrategyPriority = "priority"
+)
+
+// StrategyNames lists the names of all built-in strategies.
+var StrategyNames = []string{StrategyBootTime, StrategyLowestIP, StrategyHostname, StrategyPriority}
+
+// Strategy orders election candidates. After sorting with Less, the first
+// candidate is the leader. Less must define a strict total order (ties are
+// broken by IP) so every node elects the same leader from the same candidates.
+type Strategy interface {
+	// Name returns the strategy name.
+	Name() string
+	// Less reports whether candidate a is preferred over candidate b.
+	Less(a, b discovery.DiscoveredNode) bool
+}
+
+// NewStrategy returns the built-in strategy with the given name.
+// priorities is only used by the priority strategy.
+func NewStrategy(name string, priorities map[string]int) (Strategy, error) {
+	switch name {
+	case StrategyBootTime, "":
+		return BootTimeStrategy{}, nil
+	case StrategyLowestIP:
+		return LowestIPStrategy{}, nil
+	case StrategyHostname:
+		return HostnameStrategy{}, nil
+	case StrategyPriority:
+		return PriorityStrategy{Priorities: priorities}, nil
+	default:
+		return nil, fmt.Errorf("unknown election strategy %q, expected one of %v", name, StrategyNames)
+	}
+}
+
+// BootTimeStrategy elects the node with the oldest boot time, then the lowest IP.
+type BootTimeStrategy struct{}
+
+// Name implements Strategy.
+func (BootTimeStrategy) Name() string { return StrategyBootTime }
+
+// Less implements Strategy.
+func (BootTimeStrategy) Less(a, b discovery.DiscoveredNode) bool {
+	if a.CreationTime.Equal(b.CreationTime) {
+		// Tie-break by IP address (lowest wins)
+		return a.IP.Less(b.IP)
+	}
+	// Oldest node (earliest creation time) wins
+	return a.CreationTime.Before(b.CreationTime)
+}
+
+// LowestIPStrategy elects the node with the lowest IP address.
+type LowestIPStrategy struct{}
+
+// Name implements Strategy.
+func (LowestIPStrategy) Name() string { return StrategyLowestIP }
+
+// Less implements Strategy.
+func (LowestIPStrategy) Less(a, b discovery.DiscoveredNode) bool {
+	return a.IP.Less(b.IP)
+}
+
+// HostnameStrategy elects the node with the lexicographically smallest hostname,
+// then the lowest IP.
+type HostnameStrategy struct{}
+
+// Name implements Strategy.
+func (HostnameStrategy) Name() string { return StrategyHostname }
+
+// Less implements Strategy.
+func (HostnameStrategy) Less(a, b discovery.DiscoveredNode) bool {
+	if c := strings.Compare(a.Hostname, b.Hostname); c != 0 {
+		return c < 0
+	}
+	return a.IP.Less(b.IP)
+}
+
+// PriorityStrategy elects the node with the highest priority, then the lowest IP.
+// A node's priority is looked up in Priorities by hostname, then by IP; if it
+// is not listed, the priority discovered from its node labels/annotations is used.
+type PriorityStrategy struct {
+	Priorities map[string]int
+}
+
+// Name implements Strategy.
+func (PriorityStrategy) Name() string { return StrategyPriority }
+
+// Less implements Strategy.
+func (s PriorityStrategy) Less(a, b discovery.DiscoveredNode) bool {
+	pa, pb := s.Priority(a), s.Priority(b)
+	if pa != pb {
+		// Highest priority wins
+		return pa > pb
+	}
+	return a.IP.Less(b.IP)
+}
+
+// Priority returns the effective priority of a node.
+func (s PriorityStrategy) Priority(node discovery.DiscoveredNode) int {
+	if p, ok := s.Priorities[node.Hostname]; ok && node.Hostname != "" {
+		return p
+	}
+	if p, ok := s.lookupIP(node.IP); ok {
+		return p
+	}
+	return node.Priority
+}
+
+// lookupIP finds a configured priority by IP address.
+func (s PriorityStrategy) lookupIP(ip netip.Addr) (int, bool) {
+	if !ip.IsValid() {
+		return 0, false
+	}
+	p, ok := s.Priorities[ip.String()]
+	return p, ok
+}
@@ -0,0 +1,113 @@
+package election
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
+)
+
+// strategyTestNodes returns a local node and peers where every strategy
+// elects a different leader.
+func strategyTestNodes() (discovery.DiscoveredNode, []discovery.DiscoveredNode) {
+	now := time.Now()
+
+	localNode := discovery.DiscoveredNode{
+		IP:             netip.MustParseAddr("192.168.1.12"),
+		IsControlPlane: true,
+		CreationTime:   now, // oldest
+		Hostname:       "cp-c",
+	}
+
+	peers := []discovery.DiscoveredNode{
+		{
+			IP:             netip.MustParseAddr("192.168.1.10"), // lowest IP
+			IsControlPlane: true,
+			CreationTime:   now.Add(5 * time.Second),
+			Hostname:       "cp-b",
+		},
+		{
+			IP:             netip.MustParseAddr("192.168.1.11"),
+			IsControlPlane: true,
+			CreationTime:   now.Add(10 * time.Second),
+			Hostname:       "cp-a", // lowest hostname
+			Priority:       10,     // highest priority
+		},
+	}
+
+	return localNode, peers
+}
+
+func TestElectLeaderWithStrategy(t *testing.T) {
+	tests := []struct {
+		strategy   string
+		priorities map[string]int
+		expected   string
+	}{
+		{strategy: StrategyBootTime, expected: "192.168.1.12"},
+		{strategy: StrategyLowestIP, expected: "192.168.1.10"},
+		{strategy: StrategyHostname, expected: "192.168.1.11"},
+		{strategy: StrategyPriority, expected: "192.168.1.11"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			strategy, err := NewStrategy(tt.strategy, tt.priorities)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			localNode, peers := strategyTestNodes()
+			result := ElectLeaderWithStrategy(strategy, localNode, peers)
+
+			if result.Leader.IP.String() != tt.expected {
+				t.Errorf("expected leader %s, got %s", tt.expected, result.Leader.IP)
+			}
+			if result.Strategy != tt.strategy {
+				t.Errorf("expected strategy %s, got %s", tt.strategy, result.Strategy)
+			}
+		})
+	}
+}
+
+func TestPriorityStrategy_ConfiguredPriorities(t *testing.T) {
+	localNode, peers := strategyTestNodes()
+
+	tests := []struct {
+		name       string
+		priorities map[string]int
+		expected   string
+	}{
+		{
+			name:       "configured by hostname overrides label",
+			priorities: map[string]int{"cp-c": 20},
+			expected:   "192.168.1.12",
+		},
+		{
+			name:       "configured by IP",
+			priorities: map[string]int{"192.168.1.10": 50},
+			expected:   "192.168.1.10",
+		},
+		{
+			name:       "tie broken by lowest IP",
+			priorities: map[string]int{"cp-a": 1, "cp-b": 1, "cp-c": 1},
+			expected:   "192.168.1.10",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ElectLeaderWithStrategy(PriorityStrategy{Priorities: tt.priorities}, localNode, peers)
+			if result.Leader.IP.String() != tt.expected {
+				t.Errorf("expected leader %s, got %s", tt.expected, result.Leader.IP)
+			}
+		})
+	}
+}
+
+func TestNewStrategy_Unknown(t *testing.T) {
+	if _, err := NewStrategy("random", nil); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
@@ -0,0 +1,141 @@
+package election
+
+import (
+	"net/netip"
+	"slices"
+
+	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
+)
+
+// View check modes.
+const (
+	// ViewCheckEnforce refuses to bootstrap until the views converge
+	ViewCheckEnforce = "enforce"
+	// ViewCheckWarn logs diverging views but bootstraps anyway
+	ViewCheckWarn = "warn"
+	// ViewCheckOff does not compare the views
+	ViewCheckOff = "off"
+)
+
+// ViewCheckModes lists all view check modes.
+var ViewCheckModes = []string{ViewCheckEnforce, ViewCheckWarn, ViewCheckOff}
+
+// View is the set of control plane candidates a node took into account in its
+// last election, as sorted IP addresses.
+type View []netip.Addr
+
+// NewView returns the view of the given candidates.
+func NewView(candidates []discovery.DiscoveredNode) View {
+	view := make(View, 0, len(candidates))
+	for _, candidate := range candidates {
+		view = append(view, candidate.IP)
+	}
+	slices.SortFunc(view, netip.Addr.Compare)
+
+	return slices.Compact(view)
+}
+
+// Strings returns the view as IP address strings.
+func (v View) Strings() []string {
+	out := make([]string, 0, len(v))
+	for _, ip := range v {
+		out = append(out, ip.String())
+	}
+	return out
+}
+
+// ParseView parses IP address strings into a view.
+func ParseView(ips []string) (View, error) {
+	view := make(View, 0, len(ips))
+	for _, s := range ips {
+		ip, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, err
+		}
+		view = append(view, ip)
+	}
+	slices.SortFunc(view, netip.Addr.Compare)
+
+	return slices.Compact(view), nil
+}
+
+// ViewDivergence describes how a peer's view differs from the local view.
+type ViewDivergence struct {
+	// Peer is the peer whose view diverges
+	Peer netip.Addr
+	// Missing lists candidates in the local view the peer does not see
+	Missing []netip.Addr
+	// Extra lists candidates the peer sees that are not in the local view
+	Extra []netip.Addr
+	// Unknown is true if the peer's view could not be read
+	Unknown bool
+}
+
+// ViewCheck is the outcome of comparing the local view with the peers' views.
+type ViewCheck struct {
+	// Converged is true if every peer reported exactly the local view
+	Converged bool
+	// Divergences lists the peers whose view differs or is unknown
+	Divergences []ViewDivergence
+}
+
+// CompareViews compares the local view with the views reported by peers.
+// peers maps each candidate to its reported view; a nil view means the view
+// could not be read. Views converge only if all of them are identical, which
+// catches asymmetric partitions where some node sees candidates another
+// node cannot reach.
+func CompareViews(local View, peers map[netip.Addr]View) *ViewCheck {
+	check := &ViewCheck{Converged: true}
+
+	addrs := make([]netip.Addr, 0, len(peers))
+	for peer := range peers {
+		addrs = append(addrs, peer)
+	}
+	slices.SortFunc(addrs, netip.Addr.Compare)
+
+	for _, peer := range addrs {
+		view := peers[peer]
+		if view == nil {
+			check.Divergences = append(check.Divergences, ViewDivergence{Peer: peer, Unknown: true})
+			continue
+		}
+
+		divergence := ViewDivergence{
+			Peer:    peer,
+			Missing: difference(local, view),
+			Extra:   difference(view, local),
+		}
+		if len(divergence.Missing) > 0 || len(divergence.Extra) > 0 {
+			check.Divergences = append(check.Divergences, divergence)
+		}
+	}
+
+	check.Converged = len(check.Divergences) == 0
+
+	return check
+}
+
+// CheckViews decides whether the leader may bootstrap given its view and the
+// views reported by the other candidates, as in CompareViews. mode is one of
+// ViewCheckModes: with ViewCheckOff the views are not compared, and with
+// ViewCheckWarn diverging views are reported but do not block the bootstrap.
+func CheckViews(mode string, local View, peers map[netip.Addr]View) (*ViewCheck, bool) {
+	if mode == ViewCheckOff {
+		return &ViewCheck{Converged: true}, true
+	}
+
+	check := CompareViews(local, peers)
+
+	return check, check.Converged || mode != ViewCheckEnforce
+}
+
+// difference returns the addresses in a that are not in b.
+func difference(a, b View) []netip.Addr {
+	var out []netip.Addr
+	for _, ip := range a {
+		if !slices.Contains(b, ip) {
+			out = append(out, ip)
+		}
+	}
+	return out
+}
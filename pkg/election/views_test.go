@@ -0,0 +1,136 @@
+package election
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func mustView(t *testing.T, ips ...string) View {
+	t.Helper()
+
+	view, err := ParseView(ips)
+	if err != nil {
+		t.Fatalf("failed to parse view: %v", err)
+	}
+	return view
+}
+
+func TestCompareViews(t *testing.T) {
+	local := mustView(t, "10.0.0.1", "10.0.0.2", "10.0.0.3")
+	peer2 := netip.MustParseAddr("10.0.0.2")
+	peer3 := netip.MustParseAddr("10.0.0.3")
+
+	tests := []struct {
+		name          string
+		peers         map[netip.Addr]View
+		wantConverged bool
+		wantDiverging []netip.Addr
+	}{
+		{
+			name: "identical views",
+			peers: map[netip.Addr]View{
+				peer2: mustView(t, "10.0.0.3", "10.0.0.2", "10.0.0.1"),
+				peer3: mustView(t, "10.0.0.1", "10.0.0.2", "10.0.0.3"),
+			},
+			wantConverged: true,
+		},
+		{
+			name: "peer sees another partition",
+			peers: map[netip.Addr]View{
+				peer2: mustView(t, "10.0.0.1", "10.0.0.2", "10.0.0.3"),
+				peer3: mustView(t, "10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"),
+			},
+			wantDiverging: []netip.Addr{peer3},
+		},
+		{
+			name: "peer misses a candidate",
+			peers: map[netip.Addr]View{
+				peer2: mustView(t, "10.0.0.1", "10.0.0.2"),
+				peer3: mustView(t, "10.0.0.1", "10.0.0.2", "10.0.0.3"),
+			},
+			wantDiverging: []netip.Addr{peer2},
+		},
+		{
+			name: "unknown view",
+			peers: map[netip.Addr]View{
+				peer2: nil,
+				peer3: mustView(t, "10.0.0.1", "10.0.0.2", "10.0.0.3"),
+			},
+			wantDiverging: []netip.Addr{peer2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := CompareViews(local, tt.peers)
+			if check.Converged != tt.wantConverged {
+				t.Errorf("expected converged %v, got %v", tt.wantConverged, check.Converged)
+			}
+
+			var diverging []netip.Addr
+			for _, d := range check.Divergences {
+				diverging = append(diverging, d.Peer)
+			}
+			if !slices.Equal(diverging, tt.wantDiverging) {
+				t.Errorf("expected diverging peers %v, got %v", tt.wantDiverging, diverging)
+			}
+		})
+	}
+}
+
+func TestCompareViews_Divergence(t *testing.T) {
+	local := mustView(t, "10.0.0.1", "10.0.0.2")
+	peer := netip.MustParseAddr("10.0.0.2")
+
+	check := CompareViews(local, map[netip.Addr]View{peer: mustView(t, "10.0.0.2", "10.0.0.5")})
+	if len(check.Divergences) != 1 {
+		t.Fatalf("expected 1 divergence, got %d", len(check.Divergences))
+	}
+
+	d := check.Divergences[0]
+	if !slices.Equal(d.Missing, []netip.Addr{netip.MustParseAddr("10.0.0.1")}) {
+		t.Errorf("expected missing [10.0.0.1], got %v", d.Missing)
+	}
+	if !slices.Equal(d.Extra, []netip.Addr{netip.MustParseAddr("10.0.0.5")}) {
+		t.Errorf("expected extra [10.0.0.5], got %v", d.Extra)
+	}
+}
+
+func TestCheckViews(t *testing.T) {
+	local := mustView(t, "10.0.0.1", "10.0.0.2")
+	peer := netip.MustParseAddr("10.0.0.2")
+
+	converged := map[netip.Addr]View{peer: mustView(t, "10.0.0.1", "10.0.0.2")}
+	diverged := map[netip.Addr]View{peer: mustView(t, "10.0.0.2", "10.0.0.3")}
+	unknown := map[netip.Addr]View{peer: nil}
+
+	tests := []struct {
+		name            string
+		mode            string
+		peers           map[netip.Addr]View
+		wantProceed     bool
+		wantDivergences int
+	}{
+		{name: "enforce converged", mode: ViewCheckEnforce, peers: converged, wantProceed: true},
+		{name: "enforce diverged", mode: ViewCheckEnforce, peers: diverged, wantDivergences: 1},
+		{name: "enforce unknown", mode: ViewCheckEnforce, peers: unknown, wantDivergences: 1},
+		{name: "warn converged", mode: ViewCheckWarn, peers: converged, wantProceed: true},
+		{name: "warn diverged", mode: ViewCheckWarn, peers: diverged, wantProceed: true, wantDivergences: 1},
+		{name: "warn unknown", mode: ViewCheckWarn, peers: unknown, wantProceed: true, wantDivergences: 1},
+		{name: "off diverged", mode: ViewCheckOff, peers: diverged, wantProceed: true},
+		{name: "off unknown", mode: ViewCheckOff, peers: unknown, wantProceed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check, proceed := CheckViews(tt.mode, local, tt.peers)
+			if proceed != tt.wantProceed {
+				t.Errorf("expected proceed %v, got %v", tt.wantProceed, proceed)
+			}
+			if len(check.Divergences) != tt.wantDivergences {
+				t.Errorf("expected %d divergences, got %v", tt.wantDivergences, check.Divergences)
+			}
+		})
+	}
+}
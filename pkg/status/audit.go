@@ -0,0 +1,279 @@
+package status
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/kommodity/talos-auto-bootstrap/internal/fsutil"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
+)
+
+const (
+	// AuditFileName is the name of the election audit log within the status
+	// directory. It holds one JSON record per line, oldest first.
+	AuditFileName = "elections.jsonl"
+
+	// MaxAuditRecords is the number of records kept in the audit log. The log
+	// grows to twice this number before it is trimmed.
+	MaxAuditRecords = 500
+)
+
+// AuditRecord is a structured record of an election decision, so post-mortems
+// can reconstruct why a node bootstrapped. Consecutive identical decisions
+// are folded into one record.
+type AuditRecord struct {
+	// Time is when the decision was first made
+	Time time.Time `json:"time"`
+	// LastTime is when the same decision was last made
+	LastTime time.Time `json:"lastTime"`
+	// Count is how many times in a row the same decision was made
+	Count int `json:"count"`
+	// Strategy is the election strategy
+	Strategy string `json:"strategy"`
+	// QuorumRule describes the quorum rule that was satisfied
+	QuorumRule string `json:"quorumRule"`
+	// Candidates lists the election candidates in election order
+	Candidates []discovery.DiscoveredNode `json:"candidates"`
+	// Rejected lists the control plane nodes that were not eligible, with reasons
+	Rejected []string `json:"rejected,omitempty"`
+	// Demoted lists the IPs of leaders excluded after stalling
+	Demoted []string `json:"demoted,omitempty"`
+	// Leader is the IP of the elected leader
+	Leader string `json:"leader"`
+	// LeaderHostname is the hostname of the elected leader
+	LeaderHostname string `json:"leaderHostname,omitempty"`
+	// IsLeader is true if the local node was elected
+	IsLeader bool `json:"isLeader"`
+}
+
+// sameDecision reports whether two records describe the same decision.
+// Candidate boot times are not compared, as they are estimated for peers
+// that do not report them.
+func (r *AuditRecord) sameDecision(other *AuditRecord) bool {
+	return r.Strategy == other.Strategy &&
+		r.QuorumRule == other.QuorumRule &&
+		slices.EqualFunc(r.Candidates, other.Candidates, func(a, b discovery.DiscoveredNode) bool {
+			return a.IP == b.IP && a.Hostname == b.Hostname && a.Priority == b.Priority
+		}) &&
+		slices.Equal(r.Rejected, other.Rejected) &&
+		slices.Equal(r.Demoted, other.Demoted) &&
+		r.Leader == other.Leader &&
+		r.LeaderHostname == other.LeaderHostname &&
+		r.IsLeader == other.IsLeader
+}
+
+// Audit appends an election decision to the audit log and records it as the
+// last election in the state. Failures are logged but never interrupt the
+// bootstrap process.
+func (r *Recorder) Audit(record AuditRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last, err := r.audit.Append(record)
+	if err != nil {
+		zap.L().Warn("failed to persist election audit record", zap.Error(err))
+		return
+	}
+
+	r.state.LastElection = &last
+	if err := Write(r.dir, &r.state); err != nil {
+		zap.L().Warn("failed to persist bootstrap state", zap.Error(err))
+	}
+}
+
+// AuditLog appends election decisions to the audit log. It remembers the last
+// record and where its line starts, so a new decision is appended and a
+// repeated one rewrites only the last line, without rereading the log. The
+// log is rewritten only when it grows to twice MaxAuditRecords.
+type AuditLog struct {
+	path   string
+	loaded bool
+	// last is the last record in the log
+	last *AuditRecord
+	// offset is where the line of the last record starts
+	offset int64
+	// size is the size of the log
+	size int64
+	// lines is the number of records in the log
+	lines int
+}
+
+// NewAuditLog returns the audit log in dir. The log is read once, on the
+// first append.
+func NewAuditLog(dir string) *AuditLog {
+	return &AuditLog{path: filepath.Join(dir, AuditFileName)}
+}
+
+// Append appends a record to the audit log. If the last record describes the
+// same decision, it is updated instead. The appended or updated record is
+// returned.
+func (a *AuditLog) Append(record AuditRecord) (AuditRecord, error) {
+	if err := a.load(); err != nil {
+		return AuditRecord{}, err
+	}
+
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	record.LastTime = record.Time
+	record.Count = 1
+
+	fold := a.last != nil && a.last.sameDecision(&record)
+	if fold {
+		folded := *a.last
+		folded.LastTime = record.Time
+		folded.Count++
+		record = folded
+	}
+
+	line, err := json.Marshal(&record)
+	if err != nil {
+		return AuditRecord{}, fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	line = append(line, '\n')
+
+	if !fold && a.lines >= 2*MaxAuditRecords {
+		return record, a.trim(line)
+	}
+
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return AuditRecord{}, fmt.Errorf("failed to open %s: %w", AuditFileName, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	offset := a.size
+	if fold {
+		offset = a.offset
+		if err := f.Truncate(offset); err != nil {
+			return AuditRecord{}, fmt.Errorf("failed to write %s: %w", AuditFileName, err)
+		}
+	}
+
+	if _, err := f.Write(line); err != nil {
+		// The log may end in a partial line now, so read it again next time
+		a.loaded = false
+		return AuditRecord{}, fmt.Errorf("failed to write %s: %w", AuditFileName, err)
+	}
+
+	if !fold {
+		a.lines++
+	}
+	a.last = &record
+	a.offset = offset
+	a.size = offset + int64(len(line))
+
+	return record, nil
+}
+
+// load reads the position of the last record in the audit log, once.
+func (a *AuditLog) load() error {
+	if a.loaded {
+		return nil
+	}
+
+	a.last, a.offset, a.size, a.lines = nil, 0, 0, 0
+
+	f, err := os.Open(a.path)
+	if errors.Is(err, os.ErrNotExist) {
+		a.loaded = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStateSize)
+	for scanner.Scan() {
+		offset := a.size
+		a.size += int64(len(scanner.Bytes())) + 1
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("failed to parse audit log: %w", err)
+		}
+		a.last = &record
+		a.offset = offset
+		a.lines++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	a.loaded = true
+	return nil
+}
+
+// trim rewrites the audit log with the last MaxAuditRecords records, the
+// last of which is line.
+func (a *AuditLog) trim(line []byte) error {
+	data, err := os.ReadFile(a.path)
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	lines := bytes.SplitAfter(bytes.TrimSpace(data), []byte("\n"))
+	lines = append(lines[max(0, len(lines)-MaxAuditRecords+1):], line)
+	if last := len(lines) - 2; last >= 0 && !bytes.HasSuffix(lines[last], []byte("\n")) {
+		lines[last] = append(lines[last], '\n')
+	}
+
+	if err := fsutil.WriteFileAtomic(a.path, bytes.Join(lines, nil)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", AuditFileName, err)
+	}
+
+	// Read the position of the last record again on the next append
+	a.loaded = false
+	return nil
+}
+
+// ReadAudit loads the last MaxAuditRecords records of the audit log from dir,
+// oldest record first.
+func ReadAudit(dir string) ([]AuditRecord, error) {
+	f, err := os.Open(filepath.Join(dir, AuditFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var records []AuditRecord
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStateSize)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to parse audit log: %w", err)
+		}
+		records = append(records, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	if len(records) > MaxAuditRecords {
+		records = records[len(records)-MaxAuditRecords:]
+	}
+
+	return records, nil
+}
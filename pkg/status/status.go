@@ -0,0 +1,233 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	talosclient "github.com/siderolabs/talos/pkg/machinery/client"
+	"go.uber.org/zap"
+
+	"github.com/kommodity/talos-auto-bootstrap/internal/fsutil"
+	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
+)
+
+const (
+	// DefaultDir is the default directory for the local bootstrap state.
+	// Uses /run which is a writable tmpfs in Talos Linux, so the state
+	// survives extension restarts but not node reboots.
+	DefaultDir = "/run/autobootstrap/status"
+
+	// StateFileName is the name of the state file within the status directory.
+	StateFileName = "state.json"
+
+	// remoteReadTimeout bounds reading the state of a peer node.
+	remoteReadTimeout = 5 * time.Second
+
+	// maxStateSize bounds the size of a state file read from a peer node.
+	maxStateSize = 1 << 20
+)
+
+// Phase describes where the bootstrap process currently is.
+type Phase string
+
+// Bootstrap phases.
+const (
+	PhaseStarting       Phase = "starting"
+	PhaseDiscovering    Phase = "discovering"
+	PhaseIneligible     Phase = "ineligible"
+	PhaseWaitingQuorum  Phase = "waiting-quorum"
+	PhaseFollower       Phase = "follower"
+	PhaseFenced         Phase = "fenced"
+	PhaseViewsDiverged  Phase = "views-diverged"
+	PhaseBootstrapping  Phase = "bootstrapping"
+	PhaseBootstrapped   Phase = "bootstrapped"
+	PhaseWouldBootstrap Phase = "would-bootstrap"
+	PhaseWorker         Phase = "worker"
+)
+
+// State is the local bootstrap state persisted by the service.
+type State struct {
+	// Version is the extension version that wrote the state
+	Version string `json:"version"`
+	// Phase is the current bootstrap phase
+	Phase Phase `json:"phase"`
+	// DryRun is true if the service runs in dry-run mode
+	DryRun bool `json:"dryRun"`
+	// LocalIP is this node's IP address
+	LocalIP string `json:"localIP,omitempty"`
+	// PeersFound is the number of peers found by the last scan
+	PeersFound int `json:"peersFound"`
+	// Peers describes the peers found by the last scan
+	Peers []discovery.DiscoveredNode `json:"peers,omitempty"`
+	// ProbeOutcomes counts the probe outcomes of the last scan, e.g. found or timeout
+	ProbeOutcomes map[string]int `json:"probeOutcomes,omitempty"`
+	// RefusedRanges lists the scan ranges the last scan skipped for having more than scanMaxHosts hosts
+	RefusedRanges []string `json:"refusedRanges,omitempty"`
+	// Candidates is the number of control plane candidates in the last election
+	Candidates int `json:"candidates"`
+	// Rejected lists the control plane nodes that were not eligible for election, with reasons
+	Rejected []string `json:"rejected,omitempty"`
+	// QuorumRequired is the number of control plane nodes (or expected members) required
+	QuorumRequired int `json:"quorumRequired"`
+	// QuorumMissing lists the expected members missing in the last quorum check
+	QuorumMissing []string `json:"quorumMissing,omitempty"`
+	// Leader is the IP of the last elected leader
+	Leader string `json:"leader,omitempty"`
+	// LeaderHostname is the hostname of the last elected leader
+	LeaderHostname string `json:"leaderHostname,omitempty"`
+	// IsLeader is true if this node was the last elected leader
+	IsLeader bool `json:"isLeader"`
+	// Epoch is the current election epoch
+	Epoch uint64 `json:"epoch"`
+	// EpochLeader is the IP of the leader that claimed the current epoch
+	EpochLeader string `json:"epochLeader,omitempty"`
+	// View lists the IPs of the control plane candidates in the last election
+	View []string `json:"view,omitempty"`
+	// ViewConflicts describes peers whose view differs from this node's view
+	ViewConflicts []string `json:"viewConflicts,omitempty"`
+	// DemotedLeaders lists the IPs of leaders demoted after stalling
+	DemotedLeaders []string `json:"demotedLeaders,omitempty"`
+	// LastElection is the last record of the election audit log
+	LastElection *AuditRecord `json:"lastElection,omitempty"`
+	// LastError is the last error encountered by the bootstrap loop
+	LastError string `json:"lastError,omitempty"`
+	// StartedAt is when the service started
+	StartedAt time.Time `json:"startedAt"`
+	// UpdatedAt is when the state was last written
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// WouldBootstrap returns the peer whose state reports that it would have
+// bootstrapped the cluster in a dry run, if any. In a dry run this stands in
+// for a bootstrapped cluster, so the other nodes neither demote that peer nor
+// elect a successor. If several peers report it, the lowest IP is returned.
+func WouldBootstrap(states map[netip.Addr]*State) (netip.Addr, bool) {
+	var peer netip.Addr
+	for ip, state := range states {
+		if state != nil && state.Phase == PhaseWouldBootstrap && (!peer.IsValid() || ip.Less(peer)) {
+			peer = ip
+		}
+	}
+	return peer, peer.IsValid()
+}
+
+// Recorder keeps the local bootstrap state and persists it on every update.
+// Persistence failures are logged but never interrupt the bootstrap process.
+type Recorder struct {
+	dir   string
+	mu    sync.Mutex
+	state State
+	audit *AuditLog
+}
+
+// NewRecorder creates a recorder that persists state to dir.
+func NewRecorder(dir, version string) *Recorder {
+	now := time.Now()
+
+	r := &Recorder{
+		dir:   dir,
+		audit: NewAuditLog(dir),
+		state: State{
+			Version:   version,
+			Phase:     PhaseStarting,
+			StartedAt: now,
+		},
+	}
+
+	// The election epoch must never go backwards, even across restarts
+	if previous, err := Read(dir); err == nil {
+		r.state.Epoch = previous.Epoch
+		r.state.EpochLeader = previous.EpochLeader
+	}
+
+	r.Update(func(*State) {})
+
+	return r
+}
+
+// Update applies fn to the state and persists the result.
+func (r *Recorder) Update(fn func(*State)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fn(&r.state)
+	r.state.UpdatedAt = time.Now()
+
+	if err := Write(r.dir, &r.state); err != nil {
+		zap.L().Warn("failed to persist bootstrap state", zap.Error(err))
+	}
+}
+
+// State returns a copy of the current state.
+func (r *Recorder) State() State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.state
+}
+
+// Dir returns the status directory.
+func (r *Recorder) Dir() string {
+	return r.dir
+}
+
+// Write atomically persists the state to the status directory.
+func Write(dir string, state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(filepath.Join(dir, StateFileName), data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", StateFileName, err)
+	}
+
+	return nil
+}
+
+// Read loads the state from the status directory.
+func Read(dir string) (*State, error) {
+	data, err := os.ReadFile(filepath.Join(dir, StateFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+
+	return parse(data)
+}
+
+// ReadRemote loads the state of a peer node from its status directory.
+// The file is read through the local apid, which proxies the request to the peer.
+func ReadRemote(ctx context.Context, client *talosclient.Client, node netip.Addr, dir string) (*State, error) {
+	ctx, cancel := context.WithTimeout(ctx, remoteReadTimeout)
+	defer cancel()
+
+	r, err := client.Read(talosclient.WithNode(ctx, node.String()), filepath.Join(dir, StateFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state of %s: %w", node, err)
+	}
+	defer func() { _ = r.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(r, maxStateSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state of %s: %w", node, err)
+	}
+
+	return parse(data)
+}
+
+// parse decodes a state file.
+func parse(data []byte) (*State, error) {
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+
+	return &state, nil
+}
@@ -0,0 +1,198 @@
+package status
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kommodity/talos-auto-bootstrap/pkg/discovery"
+)
+
+func TestRecorder_PersistsUpdates(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "status")
+
+	recorder := NewRecorder(dir, "v1.2.3")
+
+	state, err := Read(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state.Phase != PhaseStarting || state.Version != "v1.2.3" {
+		t.Errorf("unexpected initial state: %+v", state)
+	}
+
+	recorder.Update(func(s *State) {
+		s.Phase = PhaseFollower
+		s.Leader = "10.0.0.10"
+		s.Candidates = 3
+	})
+
+	state, err = Read(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state.Phase != PhaseFollower || state.Leader != "10.0.0.10" || state.Candidates != 3 {
+		t.Errorf("unexpected state after update: %+v", state)
+	}
+	if state.UpdatedAt.Before(state.StartedAt) {
+		t.Error("expected updatedAt not to be before startedAt")
+	}
+
+	// No temp files are left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != StateFileName {
+		t.Errorf("expected only %s in status dir, got %v", StateFileName, entries)
+	}
+}
+
+func TestWouldBootstrap(t *testing.T) {
+	a := netip.MustParseAddr("10.0.0.1")
+	b := netip.MustParseAddr("10.0.0.2")
+	c := netip.MustParseAddr("10.0.0.3")
+
+	states := map[netip.Addr]*State{
+		a: {Phase: PhaseFollower},
+		b: nil,
+		c: {Phase: PhaseWouldBootstrap},
+	}
+	if peer, ok := WouldBootstrap(states); !ok || peer != c {
+		t.Errorf("expected %s to report would-bootstrap, got %s, %v", c, peer, ok)
+	}
+
+	states[c].Phase = PhaseBootstrapping
+	if peer, ok := WouldBootstrap(states); ok {
+		t.Errorf("expected no peer to report would-bootstrap, got %s", peer)
+	}
+}
+
+func TestRead_Missing(t *testing.T) {
+	if _, err := Read(t.TempDir()); err == nil {
+		t.Error("expected error for missing state file")
+	}
+}
+
+func TestAuditLog_FoldsRepeatedDecisions(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().UTC()
+
+	record := AuditRecord{
+		Time:       start,
+		Strategy:   "boot-time",
+		QuorumRule: "3 control plane nodes",
+		Candidates: []discovery.DiscoveredNode{
+			{IP: netip.MustParseAddr("10.0.0.1")},
+			{IP: netip.MustParseAddr("10.0.0.2")},
+			{IP: netip.MustParseAddr("10.0.0.3")},
+		},
+		Leader:   "10.0.0.1",
+		IsLeader: true,
+	}
+
+	log := NewAuditLog(dir)
+	for i := range 2 {
+		record.Time = start.Add(time.Duration(i) * time.Minute)
+		if _, err := log.Append(record); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// A restarted service keeps folding into the last record
+	record.Time = start.Add(2 * time.Minute)
+	last, err := NewAuditLog(dir).Append(record)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if last.Count != 3 {
+		t.Errorf("expected the folded record to be returned, got %+v", last)
+	}
+
+	record.Time = start.Add(time.Hour)
+	record.Leader = "10.0.0.2"
+	record.IsLeader = false
+	if _, err := NewAuditLog(dir).Append(record); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	records, err := ReadAudit(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if records[0].Count != 3 || !records[0].Time.Equal(start) || !records[0].LastTime.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("unexpected folded record: %+v", records[0])
+	}
+	if records[1].Leader != "10.0.0.2" || records[1].Count != 1 {
+		t.Errorf("unexpected second record: %+v", records[1])
+	}
+}
+
+func TestAuditLog_Trims(t *testing.T) {
+	dir := t.TempDir()
+
+	log := NewAuditLog(dir)
+	for i := range 2*MaxAuditRecords + 1 {
+		record := AuditRecord{Leader: fmt.Sprintf("10.0.%d.%d", i/256, i%256)}
+		if _, err := log.Append(record); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if i == MaxAuditRecords+9 {
+			records, err := ReadAudit(dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(records) != MaxAuditRecords {
+				t.Fatalf("expected %d records, got %d", MaxAuditRecords, len(records))
+			}
+			if records[0].Leader != "10.0.0.10" {
+				t.Errorf("expected the oldest records to be skipped, first is %s", records[0].Leader)
+			}
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, AuditFileName))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != MaxAuditRecords {
+		t.Errorf("expected the log to be trimmed to %d records, got %d", MaxAuditRecords, lines)
+	}
+
+	// Appending after a trim continues from the rewritten log
+	if _, err := log.Append(AuditRecord{Leader: "10.0.9.9"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	records, err := ReadAudit(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first, last := records[0].Leader, records[len(records)-1].Leader; first != "10.0.1.246" || last != "10.0.9.9" {
+		t.Errorf("expected records 10.0.1.246 to 10.0.9.9, got %s to %s", first, last)
+	}
+}
+
+func TestRecorder_AuditRecordsLastElection(t *testing.T) {
+	dir := t.TempDir()
+	recorder := NewRecorder(dir, "v1.2.3")
+
+	record := AuditRecord{Strategy: "lowest-ip", Leader: "10.0.0.1", IsLeader: true}
+	recorder.Audit(record)
+	recorder.Audit(record)
+
+	state, err := Read(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state.LastElection == nil || state.LastElection.Leader != "10.0.0.1" || state.LastElection.Count != 2 {
+		t.Errorf("expected the last election to be recorded, got %+v", state.LastElection)
+	}
+}
@@ -0,0 +1,194 @@
+package talosconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"time"
+
+	clientconfig "github.com/siderolabs/talos/pkg/machinery/client/config"
+	"go.uber.org/zap"
+
+	"github.com/kommodity/talos-auto-bootstrap/internal/fsutil"
+)
+
+const (
+	// DefaultContextName is used when the machine config has no cluster name.
+	DefaultContextName = "autobootstrap"
+
+	// pushTimeout is the timeout for pushing the talosconfig to an HTTP endpoint.
+	pushTimeout = 30 * time.Second
+)
+
+// Exporter writes a talosconfig for operators once the cluster has been bootstrapped.
+// The client certificate is issued up front, so the machine CA key does not
+// need to be kept around until the export happens. Its validity period starts
+// at issuance, so the time spent waiting for quorum is deducted from it.
+type Exporter struct {
+	contextName string
+	caCertB64   string
+	tlsConfig   *tls.Config
+	path        string
+	url         string
+}
+
+// NewExporter creates a talosconfig exporter. tlsConfig must hold the client
+// certificate to embed (see credentials.GenerateTLSConfigForRole). The
+// talosconfig is written to path and/or pushed to url, whichever is set.
+func NewExporter(contextName, caCertB64 string, tlsConfig *tls.Config, path, url string) *Exporter {
+	if contextName == "" {
+		contextName = DefaultContextName
+	}
+
+	return &Exporter{
+		contextName: contextName,
+		caCertB64:   caCertB64,
+		tlsConfig:   tlsConfig,
+		path:        path,
+		url:         url,
+	}
+}
+
+// Export builds a talosconfig for the given control plane endpoints and
+// delivers it to the configured destinations. A talosconfig whose certificate
+// has already expired is not exported.
+func (e *Exporter) Export(ctx context.Context, endpoints []netip.Addr) error {
+	notAfter, err := certNotAfter(e.tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	remaining := time.Until(notAfter)
+	if remaining <= 0 {
+		return fmt.Errorf("talosconfig certificate issued at startup expired at %s, increase its validity",
+			notAfter.Format(time.RFC3339))
+	}
+	zap.L().Info("exporting talosconfig",
+		zap.Time("cert_not_after", notAfter), zap.Duration("cert_remaining", remaining.Round(time.Second)))
+
+	data, err := Build(e.contextName, endpoints, e.caCertB64, e.tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	if e.path != "" {
+		if err := WriteFile(e.path, data); err != nil {
+			return err
+		}
+		zap.L().Info("talosconfig written", zap.String("path", e.path))
+	}
+
+	if e.url != "" {
+		if err := Push(ctx, e.url, data); err != nil {
+			return err
+		}
+		zap.L().Info("talosconfig pushed", zap.String("url", e.url))
+	}
+
+	return nil
+}
+
+// certNotAfter returns the expiry of the client certificate in tlsConfig.
+func certNotAfter(tlsConfig *tls.Config) (time.Time, error) {
+	if tlsConfig == nil || len(tlsConfig.Certificates) == 0 ||
+		len(tlsConfig.Certificates[0].Certificate) == 0 {
+		return time.Time{}, fmt.Errorf("TLS config has no client certificate")
+	}
+
+	cert, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse client certificate: %w", err)
+	}
+
+	return cert.NotAfter, nil
+}
+
+// Build renders a talosconfig with a single context using the client
+// certificate from tlsConfig and the base64-encoded PEM CA certificate.
+func Build(contextName string, endpoints []netip.Addr, caCertB64 string,
+	tlsConfig *tls.Config) ([]byte, error) {
+
+	if tlsConfig == nil || len(tlsConfig.Certificates) == 0 ||
+		len(tlsConfig.Certificates[0].Certificate) == 0 {
+		return nil, fmt.Errorf("TLS config has no client certificate")
+	}
+
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoints")
+	}
+
+	clientCert := tlsConfig.Certificates[0]
+	crtPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientCert.Certificate[0]})
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(clientCert.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal client key: %w", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	clear(keyDER)
+
+	addrs := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		addrs = append(addrs, endpoint.String())
+	}
+
+	config := &clientconfig.Config{
+		Context: contextName,
+		Contexts: map[string]*clientconfig.Context{
+			contextName: {
+				Endpoints: addrs,
+				Nodes:     addrs,
+				CA:        caCertB64,
+				Crt:       base64.StdEncoding.EncodeToString(crtPEM),
+				Key:       base64.StdEncoding.EncodeToString(keyPEM),
+			},
+		},
+	}
+	clear(keyPEM)
+
+	data, err := config.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal talosconfig: %w", err)
+	}
+
+	return data, nil
+}
+
+// WriteFile atomically writes the talosconfig to path with owner-only permissions.
+func WriteFile(path string, data []byte) error {
+	if err := fsutil.WriteFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write talosconfig: %w", err)
+	}
+
+	return nil
+}
+
+// Push sends the talosconfig to an HTTP endpoint using a POST request.
+func Push(ctx context.Context, url string, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, pushTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create talosconfig push request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/yaml")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push talosconfig: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to push talosconfig: unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
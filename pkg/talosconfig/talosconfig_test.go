@@ -0,0 +1,167 @@
+package talosconfig
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	clientconfig "github.com/siderolabs/talos/pkg/machinery/client/config"
+)
+
+// newTestTLSConfig returns a TLS config holding a self-signed client certificate.
+func newTestTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+
+	return newTestTLSConfigUntil(t, time.Now().Add(time.Hour))
+}
+
+// newTestTLSConfigUntil is like newTestTLSConfig, but the certificate expires at notAfter.
+func newTestTLSConfigUntil(t *testing.T, notAfter time.Time) *tls.Config {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"os:reader"}},
+		NotBefore:    notAfter.Add(-2 * time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: priv}},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	endpoints := []netip.Addr{
+		netip.MustParseAddr("10.0.0.10"),
+		netip.MustParseAddr("10.0.0.11"),
+	}
+
+	data, err := Build("prod", endpoints, "Y2E=", newTestTLSConfig(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	config, err := clientconfig.FromBytes(data)
+	if err != nil {
+		t.Fatalf("failed to parse talosconfig: %v", err)
+	}
+
+	if config.Context != "prod" {
+		t.Errorf("expected context prod, got %q", config.Context)
+	}
+
+	ctx := config.Contexts["prod"]
+	if ctx == nil {
+		t.Fatal("expected context prod to exist")
+	}
+	if len(ctx.Endpoints) != 2 || ctx.Endpoints[0] != "10.0.0.10" || ctx.Endpoints[1] != "10.0.0.11" {
+		t.Errorf("unexpected endpoints %v", ctx.Endpoints)
+	}
+	if ctx.CA != "Y2E=" {
+		t.Errorf("expected CA to be passed through, got %q", ctx.CA)
+	}
+	if ctx.Crt == "" || ctx.Key == "" {
+		t.Error("expected client certificate and key to be set")
+	}
+}
+
+func TestBuild_Errors(t *testing.T) {
+	endpoints := []netip.Addr{netip.MustParseAddr("10.0.0.10")}
+
+	if _, err := Build("prod", endpoints, "Y2E=", &tls.Config{}); err == nil {
+		t.Error("expected error for TLS config without certificate")
+	}
+	if _, err := Build("prod", nil, "Y2E=", newTestTLSConfig(t)); err == nil {
+		t.Error("expected error for empty endpoints")
+	}
+}
+
+func TestExporter_Export(t *testing.T) {
+	var pushed []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pushed, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "nested", "talosconfig")
+	exporter := NewExporter("", "Y2E=", newTestTLSConfig(t), path, server.URL)
+
+	err := exporter.Export(context.Background(), []netip.Addr{netip.MustParseAddr("10.0.0.10")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read written talosconfig: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat talosconfig: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	if string(written) != string(pushed) {
+		t.Error("expected written and pushed talosconfig to match")
+	}
+
+	config, err := clientconfig.FromBytes(written)
+	if err != nil {
+		t.Fatalf("failed to parse talosconfig: %v", err)
+	}
+	if config.Context != DefaultContextName {
+		t.Errorf("expected default context name, got %q", config.Context)
+	}
+}
+
+func TestPush_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	if err := Push(context.Background(), server.URL, []byte("data")); err == nil {
+		t.Error("expected error for non-2xx status")
+	}
+}
+
+func TestExporter_ExportExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "talosconfig")
+	exporter := NewExporter("", "Y2E=", newTestTLSConfigUntil(t, time.Now().Add(-time.Minute)), path, "")
+
+	err := exporter.Export(context.Background(), []netip.Addr{netip.MustParseAddr("10.0.0.10")})
+	if err == nil {
+		t.Fatal("expected error for expired certificate")
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected no talosconfig to be written, got %v", err)
+	}
+}